@@ -0,0 +1,358 @@
+package irest
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Matcher checks a string value, such as a header value, and returns an error
+// describing the mismatch if it does not match.
+type Matcher func(value string) error
+
+// Equals matches a value that is exactly the expected string.
+func Equals(expected string) Matcher {
+	return func(value string) error {
+		if value != expected {
+			return fmt.Errorf("expected %s, but got %s", expected, value)
+		}
+		return nil
+	}
+}
+
+// Contains matches a value containing the substring.
+func Contains(substr string) Matcher {
+	return func(value string) error {
+		if !strings.Contains(value, substr) {
+			return fmt.Errorf("expected %s to contain %s", value, substr)
+		}
+		return nil
+	}
+}
+
+// HasPrefix matches a value starting with the prefix.
+func HasPrefix(prefix string) Matcher {
+	return func(value string) error {
+		if !strings.HasPrefix(value, prefix) {
+			return fmt.Errorf("expected %s to start with %s", value, prefix)
+		}
+		return nil
+	}
+}
+
+// MatchesPattern matches a value against a regular expression. An invalid
+// pattern fails every value.
+func MatchesPattern(pattern string) Matcher {
+	re, err := regexp.Compile(pattern)
+	return func(value string) error {
+		if err != nil {
+			return err
+		}
+		if !re.MatchString(value) {
+			return fmt.Errorf("expected %s to match pattern %s", value, pattern)
+		}
+		return nil
+	}
+}
+
+// Any matches any value, which is useful to only check a header is present.
+func Any() Matcher {
+	return func(value string) error {
+		return nil
+	}
+}
+
+// CookieCheck checks an attribute of a response cookie.
+type CookieCheck func(c *http.Cookie) error
+
+// CookieValue checks the cookie value with a matcher.
+func CookieValue(m Matcher) CookieCheck {
+	return func(c *http.Cookie) error {
+		return m(c.Value)
+	}
+}
+
+// CookieSecure checks that the cookie has the Secure attribute.
+func CookieSecure() CookieCheck {
+	return func(c *http.Cookie) error {
+		if !c.Secure {
+			return fmt.Errorf("expected cookie '%s' to be Secure", c.Name)
+		}
+		return nil
+	}
+}
+
+// CookieHTTPOnly checks that the cookie has the HttpOnly attribute.
+func CookieHTTPOnly() CookieCheck {
+	return func(c *http.Cookie) error {
+		if !c.HttpOnly {
+			return fmt.Errorf("expected cookie '%s' to be HttpOnly", c.Name)
+		}
+		return nil
+	}
+}
+
+// CookieSameSite checks the SameSite attribute of the cookie.
+func CookieSameSite(mode http.SameSite) CookieCheck {
+	return func(c *http.Cookie) error {
+		if c.SameSite != mode {
+			return fmt.Errorf("expected cookie '%s' SameSite %s, but got %s", c.Name, sameSiteName(mode), sameSiteName(c.SameSite))
+		}
+		return nil
+	}
+}
+
+// CookiePath checks the Path attribute of the cookie.
+func CookiePath(path string) CookieCheck {
+	return func(c *http.Cookie) error {
+		if c.Path != path {
+			return fmt.Errorf("expected cookie '%s' path %s, but got %s", c.Name, path, c.Path)
+		}
+		return nil
+	}
+}
+
+// CookieExpiresAfter checks that the cookie is persistent and does not expire
+// for at least the given duration from now.
+func CookieExpiresAfter(d time.Duration) CookieCheck {
+	return func(c *http.Cookie) error {
+		expiry, ok := cookieExpiry(c)
+		if !ok {
+			return fmt.Errorf("expected cookie '%s' to have an expiry, but it is a session cookie", c.Name)
+		}
+		if expiry.Before(time.Now().Add(d)) {
+			return fmt.Errorf("expected cookie '%s' to expire after %s, but expires at %s", c.Name, d, expiry.Format(time.RFC1123))
+		}
+		return nil
+	}
+}
+
+// CookieExpiresBefore checks that the cookie expires within the given duration
+// from now. Session cookies without an expiry do not pass.
+func CookieExpiresBefore(d time.Duration) CookieCheck {
+	return func(c *http.Cookie) error {
+		expiry, ok := cookieExpiry(c)
+		if !ok {
+			return fmt.Errorf("expected cookie '%s' to have an expiry, but it is a session cookie", c.Name)
+		}
+		if expiry.After(time.Now().Add(d)) {
+			return fmt.Errorf("expected cookie '%s' to expire before %s, but expires at %s", c.Name, d, expiry.Format(time.RFC1123))
+		}
+		return nil
+	}
+}
+
+// CookieSession checks that the cookie has neither Expires nor Max-Age set.
+func CookieSession() CookieCheck {
+	return func(c *http.Cookie) error {
+		if _, ok := cookieExpiry(c); ok {
+			return fmt.Errorf("expected cookie '%s' to be a session cookie", c.Name)
+		}
+		return nil
+	}
+}
+
+// cookieExpiry returns when the cookie expires, preferring Max-Age over
+// Expires as browsers do. False is returned for session cookies.
+func cookieExpiry(c *http.Cookie) (time.Time, bool) {
+	switch {
+	case c.MaxAge > 0:
+		return time.Now().Add(time.Duration(c.MaxAge) * time.Second), true
+	case c.MaxAge < 0:
+		return time.Now(), true
+	case !c.Expires.IsZero():
+		return c.Expires, true
+	}
+	return time.Time{}, false
+}
+
+func sameSiteName(mode http.SameSite) string {
+	switch mode {
+	case http.SameSiteDefaultMode:
+		return "Default"
+	case http.SameSiteLaxMode:
+		return "Lax"
+	case http.SameSiteStrictMode:
+		return "Strict"
+	case http.SameSiteNoneMode:
+		return "None"
+	}
+	return "unset"
+}
+
+var errNoResponse = fmt.Errorf("http response not set, must have request before checking result")
+
+func checkHeader(res *http.Response, name string, m Matcher) error {
+	if res == nil {
+		return errNoResponse
+	}
+
+	values := res.Header.Values(name)
+	if len(values) == 0 {
+		return fmt.Errorf("header '%s' not found", name)
+	}
+
+	// Any of the values for a repeated header may match.
+	var err error
+	for _, v := range values {
+		if err = m(v); err == nil {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("header '%s': %s", name, err)
+}
+
+func checkNoHeader(res *http.Response, name string) error {
+	if res == nil {
+		return errNoResponse
+	}
+
+	if values := res.Header.Values(name); len(values) > 0 {
+		return fmt.Errorf("expected no header '%s', but got %s", name, strings.Join(values, ", "))
+	}
+
+	return nil
+}
+
+func checkContentType(res *http.Response, mediaType string) error {
+	if res == nil {
+		return errNoResponse
+	}
+
+	value := res.Header.Get("Content-Type")
+	if value == "" {
+		return fmt.Errorf("expected content type %s, but none was set", mediaType)
+	}
+
+	actual, _, err := mime.ParseMediaType(value)
+	if err != nil {
+		return fmt.Errorf("invalid content type %s: %s", value, err)
+	}
+
+	if !strings.EqualFold(actual, mediaType) {
+		return fmt.Errorf("expected content type %s, but got %s", mediaType, actual)
+	}
+
+	return nil
+}
+
+func checkCookie(res *http.Response, name string, checks []CookieCheck) error {
+	if res == nil {
+		return errNoResponse
+	}
+
+	for _, c := range res.Cookies() {
+		if c.Name != name {
+			continue
+		}
+		for _, check := range checks {
+			if err := check(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return fmt.Errorf("cookie name '%s' not found", name)
+}
+
+// MustHeader sets the Test.Error if the response header is missing or none of
+// its values match. An HTTP request must have been made prior to this function
+// call.
+func (t *Test) MustHeader(name string, m Matcher) *Test {
+	if t.Error != nil {
+		return t
+	}
+
+	t.Error = checkHeader(t.Response, name, m)
+
+	return t
+}
+
+// MustNotHaveHeader sets the Test.Error if the response has the header.
+func (t *Test) MustNotHaveHeader(name string) *Test {
+	if t.Error != nil {
+		return t
+	}
+
+	t.Error = checkNoHeader(t.Response, name)
+
+	return t
+}
+
+// MustContentType sets the Test.Error if the media type of the response
+// Content-Type is not the expected value. Parameters such as charset are
+// ignored.
+func (t *Test) MustContentType(mediaType string) *Test {
+	if t.Error != nil {
+		return t
+	}
+
+	t.Error = checkContentType(t.Response, mediaType)
+
+	return t
+}
+
+// MustCookie sets the Test.Error if the response did not set the cookie or any
+// of the checks on its attributes fail.
+func (t *Test) MustCookie(name string, checks ...CookieCheck) *Test {
+	if t.Error != nil {
+		return t
+	}
+
+	t.Error = checkCookie(t.Response, name, checks)
+
+	return t
+}
+
+// MustHeader sets the EndpointTest.Error if the response header is missing or
+// none of its values match.
+func (e *EndpointTest) MustHeader(name string, m Matcher) *EndpointTest {
+	if e.Error != nil {
+		return e
+	}
+
+	e.Error = checkHeader(e.Response, name, m)
+
+	return e
+}
+
+// MustNotHaveHeader sets the EndpointTest.Error if the response has the
+// header.
+func (e *EndpointTest) MustNotHaveHeader(name string) *EndpointTest {
+	if e.Error != nil {
+		return e
+	}
+
+	e.Error = checkNoHeader(e.Response, name)
+
+	return e
+}
+
+// MustContentType sets the EndpointTest.Error if the media type of the
+// response Content-Type is not the expected value.
+func (e *EndpointTest) MustContentType(mediaType string) *EndpointTest {
+	if e.Error != nil {
+		return e
+	}
+
+	e.Error = checkContentType(e.Response, mediaType)
+
+	return e
+}
+
+// MustCookie sets the EndpointTest.Error if the response did not set the
+// cookie or any of the checks on its attributes fail.
+func (e *EndpointTest) MustCookie(name string, checks ...CookieCheck) *EndpointTest {
+	if e.Error != nil {
+		return e
+	}
+
+	e.Error = checkCookie(e.Response, name, checks)
+
+	return e
+}
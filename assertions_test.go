@@ -0,0 +1,159 @@
+package irest
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setCookies sets session and preference cookies and a repeated header.
+func setCookies(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "session",
+		Value:    "abc123",
+		Path:     "/",
+		MaxAge:   3600,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.SetCookie(w, &http.Cookie{Name: "pref", Value: "dark"})
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Add("X-Version", "1.0")
+	w.Header().Add("X-Version", "2.0")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{}`))
+}
+
+func TestMustHeader(t *testing.T) {
+	test := NewTest("unit-test").
+		Get(api.URL, "/cookies").
+		MustHeader("X-Version", Equals("2.0")).
+		MustHeader("Content-Type", HasPrefix("application/json")).
+		MustHeader("X-Version", MatchesPattern(`^\d\.\d$`)).
+		MustNotHaveHeader("X-Powered-By")
+
+	if test.Error != nil {
+		t.Error(test.Error)
+	}
+}
+
+func TestMustHeaderMismatch(t *testing.T) {
+	var headerTests = []struct {
+		name string
+		m    Matcher
+		msg  string
+	}{
+		{"X-Version", Equals("3.0"), "expected 3.0, but got 2.0"},
+		{"X-Version", Contains("beta"), "to contain beta"},
+		{"X-Missing", Any(), "header 'X-Missing' not found"},
+		{"X-Version", MatchesPattern("("), "error parsing regexp"},
+	}
+
+	for _, ht := range headerTests {
+		test := NewTest("unit-test").
+			Get(api.URL, "/cookies").
+			MustHeader(ht.name, ht.m)
+
+		if test.Error == nil || !strings.Contains(test.Error.Error(), ht.msg) {
+			t.Errorf("expected error containing '%s', got %v", ht.msg, test.Error)
+		}
+	}
+}
+
+func TestMustNotHaveHeaderPresent(t *testing.T) {
+	test := NewTest("unit-test").
+		Get(api.URL, "/cookies").
+		MustNotHaveHeader("X-Version")
+
+	if test.Error == nil {
+		t.Error("expected an error, but did not get one")
+	}
+}
+
+func TestMustContentType(t *testing.T) {
+	test := NewTest("unit-test").
+		Get(api.URL, "/cookies").
+		MustContentType("application/json")
+
+	if test.Error != nil {
+		t.Error(test.Error)
+	}
+
+	test = NewTest("unit-test").
+		Get(api.URL, "/cookies").
+		MustContentType("text/html")
+
+	if test.Error == nil {
+		t.Error("expected an error, but did not get one")
+	}
+}
+
+func TestMustCookie(t *testing.T) {
+	test := NewTest("unit-test").
+		Get(api.URL, "/cookies").
+		MustCookie("session",
+			CookieValue(Equals("abc123")),
+			CookieSecure(),
+			CookieHTTPOnly(),
+			CookieSameSite(http.SameSiteStrictMode),
+			CookiePath("/"),
+			CookieExpiresAfter(time.Minute*30),
+			CookieExpiresBefore(time.Hour*2),
+		).
+		MustCookie("pref", CookieSession())
+
+	if test.Error != nil {
+		t.Error(test.Error)
+	}
+}
+
+func TestMustCookieFailures(t *testing.T) {
+	var cookieTests = []struct {
+		name  string
+		check CookieCheck
+		msg   string
+	}{
+		{"pref", CookieSecure(), "to be Secure"},
+		{"pref", CookieHTTPOnly(), "to be HttpOnly"},
+		{"pref", CookieSameSite(http.SameSiteLaxMode), "SameSite Lax, but got unset"},
+		{"pref", CookieExpiresAfter(time.Minute), "session cookie"},
+		{"session", CookieExpiresAfter(time.Hour * 24), "to expire after"},
+		{"session", CookieSession(), "to be a session cookie"},
+		{"missing", CookieSecure(), "cookie name 'missing' not found"},
+	}
+
+	for _, ct := range cookieTests {
+		test := NewTest("unit-test").
+			Get(api.URL, "/cookies").
+			MustCookie(ct.name, ct.check)
+
+		if test.Error == nil || !strings.Contains(test.Error.Error(), ct.msg) {
+			t.Errorf("expected error containing '%s', got %v", ct.msg, test.Error)
+		}
+	}
+}
+
+func TestMustHeaderNoResponse(t *testing.T) {
+	test := NewTest("unit-test").MustHeader("X-Version", Any())
+
+	if test.Error == nil {
+		t.Error("expected an error, but did not get one")
+	}
+}
+
+func TestEndpointMustHeader(t *testing.T) {
+	e := &Endpoint{Path: "/cookies", Method: http.MethodGet}
+
+	et := e.Use(api.URL, nil).Do().
+		MustStatus(http.StatusOK).
+		MustHeader("X-Version", Equals("1.0")).
+		MustNotHaveHeader("X-Powered-By").
+		MustContentType("application/json").
+		MustCookie("session", CookieSecure(), CookieHTTPOnly())
+
+	if et.Error != nil {
+		t.Error(et.Error)
+	}
+}
@@ -0,0 +1,264 @@
+package irest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Authenticator adds credentials to requests.
+type Authenticator interface {
+	Authenticate(req *http.Request) error
+}
+
+// AuthenticatorFunc adapts a function to an Authenticator.
+type AuthenticatorFunc func(req *http.Request) error
+
+// Authenticate calls the function.
+func (f AuthenticatorFunc) Authenticate(req *http.Request) error {
+	return f(req)
+}
+
+// invalidator is an Authenticator whose credentials can expire before it
+// knows, such as tokens revoked by the server.
+type invalidator interface {
+	Invalidate()
+}
+
+// BasicAuth authenticates requests with a username and password.
+func BasicAuth(username, password string) Authenticator {
+	return AuthenticatorFunc(func(req *http.Request) error {
+		req.SetBasicAuth(username, password)
+		return nil
+	})
+}
+
+// BearerToken authenticates requests with a static bearer token.
+func BearerToken(token string) Authenticator {
+	return AuthenticatorFunc(func(req *http.Request) error {
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	})
+}
+
+// APIKeyHeader authenticates requests with an API key in a header.
+func APIKeyHeader(name, key string) Authenticator {
+	return AuthenticatorFunc(func(req *http.Request) error {
+		req.Header.Set(name, key)
+		return nil
+	})
+}
+
+// APIKeyQuery authenticates requests with an API key in a query parameter.
+func APIKeyQuery(name, key string) Authenticator {
+	return AuthenticatorFunc(func(req *http.Request) error {
+		q := req.URL.Query()
+		q.Set(name, key)
+		req.URL.RawQuery = q.Encode()
+		return nil
+	})
+}
+
+// OAuth2 authenticates requests with bearer tokens fetched from a token
+// endpoint with the client credentials or password grant. Tokens are cached
+// until they expire, then renewed with the refresh token when one was issued
+// or fetched again otherwise.
+type OAuth2 struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+
+	// Username and Password select the password grant when set.
+	Username string
+	Password string
+
+	// ExpiryDelta renews tokens this long before they expire.
+	ExpiryDelta time.Duration
+
+	// Client makes token requests, http.DefaultClient when nil.
+	Client *http.Client
+
+	mu           sync.Mutex
+	token        string
+	refreshToken string
+	expiry       time.Time
+}
+
+// ClientCredentials creates an OAuth2 authenticator using the client
+// credentials grant.
+func ClientCredentials(tokenURL, clientID, clientSecret string, scopes ...string) *OAuth2 {
+	return &OAuth2{
+		TokenURL:     tokenURL,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Scopes:       scopes,
+		ExpiryDelta:  10 * time.Second,
+	}
+}
+
+// PasswordGrant creates an OAuth2 authenticator using the resource owner
+// password grant.
+func PasswordGrant(tokenURL, clientID, clientSecret, username, password string, scopes ...string) *OAuth2 {
+	o := ClientCredentials(tokenURL, clientID, clientSecret, scopes...)
+	o.Username = username
+	o.Password = password
+	return o
+}
+
+// Authenticate sets the bearer token, fetching one first if needed.
+func (o *OAuth2) Authenticate(req *http.Request) error {
+	token, err := o.Token()
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// Token returns a valid access token, from the cache when it has not expired.
+func (o *OAuth2) Token() (string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.token != "" && (o.expiry.IsZero() || time.Now().Add(o.ExpiryDelta).Before(o.expiry)) {
+		return o.token, nil
+	}
+
+	if o.refreshToken != "" {
+		err := o.fetch(url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {o.refreshToken},
+		})
+		if err == nil {
+			return o.token, nil
+		}
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if o.Username != "" {
+		form = url.Values{
+			"grant_type": {"password"},
+			"username":   {o.Username},
+			"password":   {o.Password},
+		}
+	}
+	if len(o.Scopes) > 0 {
+		form.Set("scope", strings.Join(o.Scopes, " "))
+	}
+
+	if err := o.fetch(form); err != nil {
+		return "", err
+	}
+	return o.token, nil
+}
+
+// Invalidate drops the cached access token so the next request fetches a
+// new one.
+func (o *OAuth2) Invalidate() {
+	o.mu.Lock()
+	o.token = ""
+	o.mu.Unlock()
+}
+
+// fetch requests a token from the token endpoint and caches it.
+func (o *OAuth2) fetch(form url.Values) error {
+	req, err := http.NewRequest(http.MethodPost, o.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(o.ClientID), url.QueryEscape(o.ClientSecret))
+
+	client := o.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("token request failed with status %d: %s", res.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var token struct {
+		AccessToken  string `json:"access_token"`
+		TokenType    string `json:"token_type"`
+		ExpiresIn    int64  `json:"expires_in"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return err
+	}
+
+	if token.AccessToken == "" {
+		return fmt.Errorf("token response has no access_token")
+	}
+
+	o.token = token.AccessToken
+	o.expiry = time.Time{}
+	if token.ExpiresIn > 0 {
+		o.expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	if token.RefreshToken != "" {
+		o.refreshToken = token.RefreshToken
+	}
+
+	return nil
+}
+
+// Auth authenticates the requests of the test and of sub-tests created after
+// it. When a request is rejected with 401 Unauthorized and the
+// authenticator's credentials can be invalidated, as OAuth2 tokens can, the
+// request is retried once with new credentials.
+func (t *Test) Auth(a Authenticator) *Test {
+	return t.AddMiddleware(func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			authed := req.Clone(req.Context())
+			if err := a.Authenticate(authed); err != nil {
+				return nil, err
+			}
+
+			res, err := next.RoundTrip(authed)
+			if err != nil || res.StatusCode != http.StatusUnauthorized {
+				return res, err
+			}
+
+			inv, ok := a.(invalidator)
+			if !ok || (req.Body != nil && req.Body != http.NoBody && req.GetBody == nil) {
+				return res, nil
+			}
+			inv.Invalidate()
+
+			retry := req.Clone(req.Context())
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return res, nil
+				}
+				retry.Body = body
+			}
+			if err := a.Authenticate(retry); err != nil {
+				return res, nil
+			}
+
+			res.Body.Close()
+			return next.RoundTrip(retry)
+		})
+	})
+}
@@ -0,0 +1,199 @@
+package irest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// tokenServer is a stand-in OAuth2 token endpoint and an API accepting the
+// tokens it issued.
+type tokenServer struct {
+	mu        sync.Mutex
+	issued    map[string]bool
+	grants    []string
+	expiresIn int
+	refresh   bool
+	server    *httptest.Server
+}
+
+func newTokenServer(expiresIn int, refresh bool) *tokenServer {
+	ts := &tokenServer{issued: map[string]bool{}, expiresIn: expiresIn, refresh: refresh}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != "client" || secret != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":"invalid_client"}`)
+			return
+		}
+
+		grant := r.PostFormValue("grant_type")
+		if grant == "password" && (r.PostFormValue("username") != "user" || r.PostFormValue("password") != "pass") {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"invalid_grant"}`)
+			return
+		}
+
+		ts.mu.Lock()
+		ts.grants = append(ts.grants, grant+" "+r.PostFormValue("scope"))
+		token := fmt.Sprintf("token-%d", len(ts.grants))
+		ts.issued[token] = true
+		ts.mu.Unlock()
+
+		res := map[string]interface{}{"access_token": token, "token_type": "bearer", "expires_in": ts.expiresIn}
+		if ts.refresh {
+			res["refresh_token"] = "refresh-" + token
+		}
+		json.NewEncoder(w).Encode(res)
+	})
+	mux.HandleFunc("/things", func(w http.ResponseWriter, r *http.Request) {
+		ts.mu.Lock()
+		ok := len(r.Header.Get("Authorization")) > 7 && ts.issued[r.Header.Get("Authorization")[7:]]
+		ts.mu.Unlock()
+
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	ts.server = httptest.NewServer(mux)
+	return ts
+}
+
+func (ts *tokenServer) revokeAll() {
+	ts.mu.Lock()
+	ts.issued = map[string]bool{}
+	ts.mu.Unlock()
+}
+
+func (ts *tokenServer) grantLog() []string {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return append([]string(nil), ts.grants...)
+}
+
+func TestStaticAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		fmt.Fprintf(w, `{"basic":%q,"authorization":%q,"key":%q,"query":%q}`,
+			user+":"+pass, r.Header.Get("Authorization"), r.Header.Get("X-API-Key"), r.URL.Query().Get("api_key"))
+	}))
+	defer server.Close()
+
+	type echo struct {
+		Basic         string `json:"basic"`
+		Authorization string `json:"authorization"`
+		Key           string `json:"key"`
+		Query         string `json:"query"`
+	}
+
+	var authTests = []struct {
+		auth     Authenticator
+		expected echo
+	}{
+		{BasicAuth("user", "pass"), echo{Basic: "user:pass", Authorization: "Basic dXNlcjpwYXNz"}},
+		{BearerToken("abc"), echo{Basic: ":", Authorization: "Bearer abc"}},
+		{APIKeyHeader("X-API-Key", "key"), echo{Basic: ":", Key: "key"}},
+		{APIKeyQuery("api_key", "key"), echo{Basic: ":", Query: "key"}},
+	}
+
+	for _, tt := range authTests {
+		var actual echo
+		test := NewTest("auth").Auth(tt.auth)
+		test.Get(server.URL, "/things?page=1").ParseResponseBody(&actual)
+		if test.Error != nil {
+			t.Fatal(test.Error)
+		}
+		if actual != tt.expected {
+			t.Errorf("expected %+v, got %+v", tt.expected, actual)
+		}
+	}
+}
+
+func TestOAuth2ClientCredentials(t *testing.T) {
+	ts := newTokenServer(3600, false)
+	defer ts.server.Close()
+
+	test := NewTest("oauth2").Auth(ClientCredentials(ts.server.URL+"/token", "client", "secret", "read", "write"))
+	for i := 0; i < 3; i++ {
+		test.NewTest("get").Get(ts.server.URL, "/things").MustStatus(http.StatusOK)
+	}
+
+	for _, c := range test.Tests {
+		if c.Error != nil {
+			t.Fatal(c.Error)
+		}
+	}
+
+	if grants := ts.grantLog(); len(grants) != 1 || grants[0] != "client_credentials read write" {
+		t.Errorf("expected one cached client credentials token, got %v", grants)
+	}
+}
+
+func TestOAuth2PasswordRefreshOnExpiry(t *testing.T) {
+	ts := newTokenServer(60, true)
+	defer ts.server.Close()
+
+	auth := PasswordGrant(ts.server.URL+"/token", "client", "secret", "user", "pass")
+	auth.ExpiryDelta = time.Minute
+
+	test := NewTest("oauth2").Auth(auth)
+	test.NewTest("first").Get(ts.server.URL, "/things").MustStatus(http.StatusOK)
+	test.NewTest("second").Get(ts.server.URL, "/things").MustStatus(http.StatusOK)
+
+	for _, c := range test.Tests {
+		if c.Error != nil {
+			t.Fatal(c.Error)
+		}
+	}
+
+	grants := ts.grantLog()
+	if len(grants) != 2 || grants[0] != "password " || grants[1] != "refresh_token " {
+		t.Errorf("expected a password grant then a refresh, got %v", grants)
+	}
+}
+
+func TestOAuth2RetryOnUnauthorized(t *testing.T) {
+	ts := newTokenServer(3600, false)
+	defer ts.server.Close()
+
+	test := NewTest("oauth2").Auth(ClientCredentials(ts.server.URL+"/token", "client", "secret"))
+	test.NewTest("first").Get(ts.server.URL, "/things").MustStatus(http.StatusOK)
+
+	ts.revokeAll()
+
+	second := test.NewTest("second").Post(ts.server.URL, "/things", map[string]string{"name": "thing"}).MustStatus(http.StatusOK)
+	if second.Error != nil {
+		t.Fatal(second.Error)
+	}
+
+	if grants := ts.grantLog(); len(grants) != 2 {
+		t.Errorf("expected a new token after the 401, got %v", grants)
+	}
+}
+
+func TestOAuth2Errors(t *testing.T) {
+	ts := newTokenServer(3600, false)
+	defer ts.server.Close()
+
+	test := NewTest("oauth2").Auth(ClientCredentials(ts.server.URL+"/token", "client", "wrong"))
+	test.Get(ts.server.URL, "/things")
+
+	if test.Error == nil {
+		t.Fatal("expected an error for rejected client credentials")
+	}
+
+	expected := `token request failed with status 401: {"error":"invalid_client"}`
+	if actual := test.Error.Error(); !strings.Contains(actual, expected) {
+		t.Errorf("expected %s, got %s", expected, actual)
+	}
+}
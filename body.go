@@ -0,0 +1,249 @@
+package irest
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// BodyEncoder encodes a payload as a request body of its content type.
+// Payloads that are not BodyEncoders are encoded by their type: url.Values
+// as a form, []byte and io.Reader as raw bytes, anything else as JSON.
+type BodyEncoder interface {
+	EncodeBody() (contentType string, body []byte, err error)
+}
+
+// BodyEncoderFunc adapts a function to a BodyEncoder.
+type BodyEncoderFunc func() (string, []byte, error)
+
+// EncodeBody calls the function.
+func (f BodyEncoderFunc) EncodeBody() (string, []byte, error) {
+	return f()
+}
+
+// bodyHeaderer is a BodyEncoder whose body needs request headers besides
+// Content-Type, such as SOAPAction.
+type bodyHeaderer interface {
+	BodyHeader() http.Header
+}
+
+// setBodyHeaders sets the content type and other headers of a payload that
+// the request does not already have.
+func setBodyHeaders(h http.Header, payload interface{}, contentType string) {
+	if contentType != "" && h.Get("Content-Type") == "" {
+		h.Set("Content-Type", contentType)
+	}
+
+	if b, ok := payload.(bodyHeaderer); ok {
+		for name, values := range b.BodyHeader() {
+			if h.Get(name) == "" {
+				h[name] = values
+			}
+		}
+	}
+}
+
+// decodeBody decodes a response body into result with the decoder for its
+// content type. XML types and forms, into *url.Values or
+// *map[string]string, have their own decoders, text can be read into
+// *string or *[]byte, and anything else is decoded as JSON.
+func decodeBody(contentType string, body []byte, result interface{}) error {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = ""
+	}
+
+	switch {
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		return xml.Unmarshal(body, result)
+	case mediaType == "application/x-www-form-urlencoded":
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return err
+		}
+		switch r := result.(type) {
+		case *url.Values:
+			*r = values
+		case *map[string]string:
+			*r = map[string]string{}
+			for name := range values {
+				(*r)[name] = values.Get(name)
+			}
+		default:
+			return fmt.Errorf("cannot parse form into %T", result)
+		}
+		return nil
+	case strings.HasPrefix(mediaType, "text/"):
+		switch r := result.(type) {
+		case *string:
+			*r = string(body)
+			return nil
+		case *[]byte:
+			*r = append([]byte(nil), body...)
+			return nil
+		}
+	}
+
+	return json.Unmarshal(body, result)
+}
+
+// encodeBody encodes a payload with its encoder or by its type.
+func encodeBody(payload interface{}) (string, []byte, error) {
+	switch p := payload.(type) {
+	case nil:
+		return "", nil, nil
+	case BodyEncoder:
+		return p.EncodeBody()
+	case url.Values:
+		return Form(p).EncodeBody()
+	case []byte:
+		return Raw("application/octet-stream", p).EncodeBody()
+	case io.Reader:
+		return RawReader("application/octet-stream", p).EncodeBody()
+	default:
+		return JSON(p).EncodeBody()
+	}
+}
+
+// JSON encodes the value as JSON.
+func JSON(v interface{}) BodyEncoder {
+	return BodyEncoderFunc(func() (string, []byte, error) {
+		b := new(bytes.Buffer)
+		if err := json.NewEncoder(b).Encode(v); err != nil {
+			return "", nil, err
+		}
+		return "application/json", b.Bytes(), nil
+	})
+}
+
+// XML encodes the value as XML with a declaration.
+func XML(v interface{}) BodyEncoder {
+	return BodyEncoderFunc(func() (string, []byte, error) {
+		body, err := xml.Marshal(v)
+		if err != nil {
+			return "", nil, err
+		}
+		return "application/xml; charset=utf-8", append([]byte(xml.Header), body...), nil
+	})
+}
+
+// Form encodes the values as a URL encoded form.
+func Form(values url.Values) BodyEncoder {
+	return BodyEncoderFunc(func() (string, []byte, error) {
+		return "application/x-www-form-urlencoded", []byte(values.Encode()), nil
+	})
+}
+
+// Raw sends the bytes as they are with the content type.
+func Raw(contentType string, b []byte) BodyEncoder {
+	return BodyEncoderFunc(func() (string, []byte, error) {
+		return contentType, b, nil
+	})
+}
+
+// RawReader sends what is read from r with the content type. It is read once,
+// when the body is first encoded, so the same body can be sent again.
+func RawReader(contentType string, r io.Reader) BodyEncoder {
+	var once sync.Once
+	var b []byte
+	var err error
+
+	return BodyEncoderFunc(func() (string, []byte, error) {
+		once.Do(func() {
+			b, err = ioutil.ReadAll(r)
+		})
+		return contentType, b, err
+	})
+}
+
+// Multipart encodes fields and files as multipart/form-data.
+type Multipart struct {
+	parts []multipartPart
+}
+
+type multipartPart struct {
+	name     string
+	filename string
+	value    []byte
+	path     string
+}
+
+// NewMultipart creates an empty multipart body.
+func NewMultipart() *Multipart {
+	return &Multipart{}
+}
+
+// Field adds a form field.
+func (m *Multipart) Field(name, value string) *Multipart {
+	m.parts = append(m.parts, multipartPart{name: name, value: []byte(value)})
+	return m
+}
+
+// File adds a file upload with the content.
+func (m *Multipart) File(field, filename string, content []byte) *Multipart {
+	m.parts = append(m.parts, multipartPart{name: field, filename: filename, value: content})
+	return m
+}
+
+// FileFromDisk adds a file upload read from the path when the body is
+// encoded.
+func (m *Multipart) FileFromDisk(field, path string) *Multipart {
+	m.parts = append(m.parts, multipartPart{name: field, filename: filepath.Base(path), path: path})
+	return m
+}
+
+// EncodeBody writes the parts with a random boundary.
+func (m *Multipart) EncodeBody() (string, []byte, error) {
+	b := new(bytes.Buffer)
+	w := multipart.NewWriter(b)
+
+	for _, p := range m.parts {
+		if p.filename == "" {
+			if err := w.WriteField(p.name, string(p.value)); err != nil {
+				return "", nil, err
+			}
+			continue
+		}
+
+		content := p.value
+		if p.path != "" {
+			var err error
+			if content, err = ioutil.ReadFile(p.path); err != nil {
+				return "", nil, err
+			}
+		}
+
+		contentType := mime.TypeByExtension(filepath.Ext(p.filename))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+
+		h := textproto.MIMEHeader{}
+		h.Set("Content-Disposition", mime.FormatMediaType("form-data", map[string]string{"name": p.name, "filename": p.filename}))
+		h.Set("Content-Type", contentType)
+		part, err := w.CreatePart(h)
+		if err != nil {
+			return "", nil, err
+		}
+		if _, err := part.Write(content); err != nil {
+			return "", nil, err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return "", nil, err
+	}
+
+	return w.FormDataContentType(), b.Bytes(), nil
+}
@@ -0,0 +1,217 @@
+package irest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// received is what echoBody saw of a request body.
+type received struct {
+	ContentType   string            `json:"contentType"`
+	ContentLength int64             `json:"contentLength"`
+	Body          string            `json:"body"`
+	Fields        map[string]string `json:"fields"`
+	Files         map[string]string `json:"files"`
+}
+
+// echoBody responds with the content type, length and body it received, and
+// the fields and files of forms.
+func echoBody(w http.ResponseWriter, r *http.Request) {
+	rec := received{
+		ContentType:   r.Header.Get("Content-Type"),
+		ContentLength: r.ContentLength,
+		Fields:        map[string]string{},
+		Files:         map[string]string{},
+	}
+
+	switch {
+	case strings.HasPrefix(rec.ContentType, "multipart/form-data"):
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		for name, values := range r.MultipartForm.Value {
+			rec.Fields[name] = values[0]
+		}
+		for name, files := range r.MultipartForm.File {
+			f, _ := files[0].Open()
+			b, _ := ioutil.ReadAll(f)
+			f.Close()
+			rec.Files[name] = fmt.Sprintf("%s %s %s", files[0].Filename, files[0].Header.Get("Content-Type"), b)
+		}
+	case rec.ContentType == "application/x-www-form-urlencoded":
+		r.ParseForm()
+		for name := range r.PostForm {
+			rec.Fields[name] = r.PostForm.Get(name)
+		}
+	default:
+		b, _ := ioutil.ReadAll(r.Body)
+		rec.Body = string(b)
+	}
+
+	writeJSON(w, rec)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_, b, _ := JSON(v).EncodeBody()
+	w.Write(b)
+}
+
+type note struct {
+	XMLName xml.Name `xml:"note"`
+	To      string   `xml:"to"`
+}
+
+func TestBodyEncodings(t *testing.T) {
+	var bodyTests = []struct {
+		payload     interface{}
+		contentType string
+		body        string
+		fields      map[string]string
+	}{
+		{map[string]string{"name": "thing"}, "application/json", "{\"name\":\"thing\"}\n", nil},
+		{JSON([]int{1, 2}), "application/json", "[1,2]\n", nil},
+		{XML(note{To: "you"}), "application/xml; charset=utf-8", xml.Header + "<note><to>you</to></note>", nil},
+		{url.Values{"name": {"thing"}, "tag": {"a b"}}, "application/x-www-form-urlencoded", "", map[string]string{"name": "thing", "tag": "a b"}},
+		{Form(url.Values{"q": {"x&y"}}), "application/x-www-form-urlencoded", "", map[string]string{"q": "x&y"}},
+		{[]byte{0, 1, 2}, "application/octet-stream", "\x00\x01\x02", nil},
+		{strings.NewReader("from a reader"), "application/octet-stream", "from a reader", nil},
+		{Raw("text/csv", []byte("a,b\n1,2\n")), "text/csv", "a,b\n1,2\n", nil},
+		{RawReader("text/plain", strings.NewReader("plain")), "text/plain", "plain", nil},
+	}
+
+	for _, tt := range bodyTests {
+		var rec received
+		test := NewTest("body").Post(api.URL, "/body", tt.payload).MustStatus(http.StatusOK).ParseResponseBody(&rec)
+		if test.Error != nil {
+			t.Fatal(test.Error)
+		}
+
+		if rec.ContentType != tt.contentType || rec.Body != tt.body {
+			t.Errorf("expected %s %q, got %s %q", tt.contentType, tt.body, rec.ContentType, rec.Body)
+		}
+
+		if rec.ContentLength != int64(len(test.requestBody)) {
+			t.Errorf("expected content length %d, got %d", len(test.requestBody), rec.ContentLength)
+		}
+
+		for name, value := range tt.fields {
+			if rec.Fields[name] != value {
+				t.Errorf("expected field %s=%q, got %q", name, value, rec.Fields[name])
+			}
+		}
+	}
+}
+
+func TestMultipart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.txt")
+	if err := os.WriteFile(path, []byte("from disk"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	body := NewMultipart().
+		Field("title", "Quarterly").
+		File("data", "data.json", []byte(`{"a":1}`)).
+		FileFromDisk("report", path)
+
+	create := &Endpoint{Path: "/body/uploads", Method: http.MethodPost}
+	var rec received
+	e := create.Use(api.URL, body).Do().MustStatus(http.StatusOK).ParseResponseBody(&rec)
+	if e.Error != nil {
+		t.Fatal(e.Error)
+	}
+
+	if !strings.HasPrefix(rec.ContentType, "multipart/form-data; boundary=") {
+		t.Errorf("expected multipart content type, got %s", rec.ContentType)
+	}
+
+	expected := map[string]string{
+		"data":   `data.json application/json {"a":1}`,
+		"report": "report.txt text/plain; charset=utf-8 from disk",
+	}
+	for name, value := range expected {
+		if rec.Files[name] != value {
+			t.Errorf("expected file %s to be %q, got %q", name, value, rec.Files[name])
+		}
+	}
+	if rec.Fields["title"] != "Quarterly" {
+		t.Errorf("expected field title, got %v", rec.Fields)
+	}
+
+	missing := NewTest("missing").Post(api.URL, "/body/uploads", NewMultipart().FileFromDisk("f", filepath.Join(t.TempDir(), "none")))
+	if missing.Error == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestContentTypeHeaderKept(t *testing.T) {
+	var rec received
+	test := NewTest("header").AddHeader("Content-Type", "application/vnd.api+json")
+	test.Post(api.URL, "/body", map[string]int{"a": 1}).ParseResponseBody(&rec)
+	if test.Error != nil {
+		t.Fatal(test.Error)
+	}
+
+	if rec.ContentType != "application/vnd.api+json" {
+		t.Errorf("expected the set content type to be kept, got %s", rec.ContentType)
+	}
+
+	var get received
+	NewTest("get").Get(api.URL, "/body").ParseResponseBody(&get)
+	if get.ContentType != "" || get.ContentLength != 0 {
+		t.Errorf("expected no body for a request without payload, got %+v", get)
+	}
+}
+
+func TestParseResponseBodyContentType(t *testing.T) {
+	serve := func(contentType, body string) *Test {
+		return NewTest("parse "+contentType).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", contentType)
+			w.Write([]byte(body))
+		})).Get("", "/")
+	}
+
+	var item struct {
+		Name string `xml:"name" json:"name"`
+	}
+	if err := serve("application/xml; charset=utf-8", "<item><name>hat</name></item>").ParseResponseBody(&item).Error; err != nil || item.Name != "hat" {
+		t.Errorf("expected XML item hat, got %q, %v", item.Name, err)
+	}
+
+	item.Name = ""
+	if err := serve("application/atom+xml", "<item><name>feed</name></item>").ParseResponseBody(&item).Error; err != nil || item.Name != "feed" {
+		t.Errorf("expected +xml item feed, got %q, %v", item.Name, err)
+	}
+
+	item.Name = ""
+	if err := serve("application/json", `{"name":"scarf"}`).ParseResponseBody(&item).Error; err != nil || item.Name != "scarf" {
+		t.Errorf("expected JSON item scarf, got %q, %v", item.Name, err)
+	}
+
+	var values url.Values
+	if err := serve("application/x-www-form-urlencoded", "a=1&a=2&b=3").ParseResponseBody(&values).Error; err != nil || len(values["a"]) != 2 || values.Get("b") != "3" {
+		t.Errorf("expected form values, got %v, %v", values, err)
+	}
+
+	var fields map[string]string
+	if err := serve("application/x-www-form-urlencoded", "a=1&b=3").ParseResponseBody(&fields).Error; err != nil || fields["a"] != "1" || fields["b"] != "3" {
+		t.Errorf("expected form fields, got %v, %v", fields, err)
+	}
+
+	if err := serve("application/x-www-form-urlencoded", "a=1").ParseResponseBody(&item).Error; err == nil {
+		t.Error("expected an error parsing a form into a struct")
+	}
+
+	var text string
+	if err := serve("text/plain", "hello").ParseResponseBody(&text).Error; err != nil || text != "hello" {
+		t.Errorf("expected text hello, got %q, %v", text, err)
+	}
+}
@@ -21,9 +21,9 @@ func main() {
 	ex := &example{}
 	t.NewEndpointsTest("Example",
 		// TODO(bsedg): only execute if previous test passes
-		loginBase.Use("api/", nil).MustStatus(http.StatusOK).Do().SaveHeader("x-authentication", "AUTH"),
+		t.Use(loginBase, "api/", nil).MustStatus(http.StatusOK).Do().SaveHeader("x-authentication", "AUTH"),
 		// TODO(bsedg): execute request on last Use before Must...
-		createExample.Use("api/", ex).UseHeader("AUTH", "x-authentication").Do().MustStatus(http.StatusCreated).ParseResponseBody(ex),
-		getExample.Use("api/", nil, ex.ID).UseHeader("AUTH", "x-authentication").Do().MustStatus(http.StatusOK),
+		t.Use(createExample, "api/", ex).UseHeader("AUTH", "x-authentication").Do().MustStatus(http.StatusCreated).ParseResponseBody(ex),
+		t.Use(getExample, "api/", nil, ex.ID).UseHeader("AUTH", "x-authentication").Do().MustStatus(http.StatusOK),
 	)
 }
@@ -2,6 +2,8 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"time"
 
 	"github.com/bsedg/irest"
 )
@@ -11,15 +13,15 @@ func main() {
 
 	// Example tests with artificial data.
 	t1 := t.NewTest("example - 1")
-	t1.Duration = 50
+	t1.Duration = 50 * time.Millisecond
 	t1.Endpoint = "/examples/1"
 	t1.Error = nil
 
 	t2 := t.NewTest("example - 2")
-	t2.Duration = 400
+	t2.Duration = 400 * time.Millisecond
 	t2.Endpoint = "/examples/2"
 	t2.Error = fmt.Errorf("expected different result")
 
-	r := irest.NewColoredCommandLineReport(t)
+	r := irest.NewCommandLineReport(t, os.Stdout)
 	r.PrintResults()
 }
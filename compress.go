@@ -0,0 +1,299 @@
+package irest
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Encoding compresses and decompresses bodies with an HTTP content coding.
+type Encoding struct {
+	NewWriter func(w io.Writer) (io.WriteCloser, error)
+	NewReader func(r io.Reader) (io.ReadCloser, error)
+}
+
+var (
+	encodingsMu sync.RWMutex
+	encodings   = map[string]Encoding{
+		"gzip": {
+			NewWriter: func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil },
+			NewReader: func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) },
+		},
+		"deflate": {
+			NewWriter: func(w io.Writer) (io.WriteCloser, error) { return zlib.NewWriter(w), nil },
+			NewReader: func(r io.Reader) (io.ReadCloser, error) { return newDeflateReader(r) },
+		},
+	}
+)
+
+// newDeflateReader reads deflate bodies, which are zlib streams, and raw
+// deflate streams some servers send instead.
+func newDeflateReader(r io.Reader) (io.ReadCloser, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if zr, err := zlib.NewReader(bytes.NewReader(b)); err == nil {
+		return zr, nil
+	}
+	return flate.NewReader(bytes.NewReader(b)), nil
+}
+
+// RegisterEncoding adds or replaces a content coding, such as br or zstd
+// which the standard library does not implement, for decoding responses and
+// compressing request bodies. gzip and deflate are registered already.
+func RegisterEncoding(name string, e Encoding) {
+	encodingsMu.Lock()
+	defer encodingsMu.Unlock()
+	encodings[strings.ToLower(name)] = e
+}
+
+func lookupEncoding(name string) (Encoding, error) {
+	encodingsMu.RLock()
+	defer encodingsMu.RUnlock()
+	e, ok := encodings[strings.ToLower(name)]
+	if !ok {
+		return Encoding{}, fmt.Errorf("no encoding %s registered", name)
+	}
+	return e, nil
+}
+
+// contentCodings splits a Content-Encoding header into its codings, in the
+// order they were applied, leaving out identity.
+func contentCodings(header string) []string {
+	var codings []string
+	for _, c := range strings.Split(header, ",") {
+		if c = strings.TrimSpace(c); c != "" && !strings.EqualFold(c, "identity") {
+			codings = append(codings, c)
+		}
+	}
+	return codings
+}
+
+// compress encodes the body with the content coding.
+func compress(name string, body []byte) ([]byte, error) {
+	e, err := lookupEncoding(name)
+	if err != nil {
+		return nil, err
+	}
+
+	b := new(bytes.Buffer)
+	w, err := e.NewWriter(b)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(body); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// Compression is the content coding of a response body and its size before
+// and after decoding. DecodedSize is 0 when no encoding is registered for a
+// coding, and the body is kept encoded.
+type Compression struct {
+	Encoding    string `json:"encoding"`
+	Size        int    `json:"size"`
+	DecodedSize int    `json:"decodedSize"`
+}
+
+// Ratio is the encoded size as a fraction of the decoded size.
+func (c Compression) Ratio() float64 {
+	if c.DecodedSize == 0 {
+		return 0
+	}
+	return float64(c.Size) / float64(c.DecodedSize)
+}
+
+// String formats the sizes for display.
+func (c Compression) String() string {
+	if c.DecodedSize == 0 {
+		return fmt.Sprintf("%s %d bytes, not decoded", c.Encoding, c.Size)
+	}
+	return fmt.Sprintf("%s %d bytes, %d decoded (%.0f%%)", c.Encoding, c.Size, c.DecodedSize, 100*c.Ratio())
+}
+
+// bodyless reports whether the response cannot have a body, whatever its
+// headers say.
+func bodyless(res *http.Response) bool {
+	switch {
+	case res.Request != nil && res.Request.Method == http.MethodHead:
+		return true
+	case res.StatusCode >= 100 && res.StatusCode < 200:
+		return true
+	case res.StatusCode == http.StatusNoContent || res.StatusCode == http.StatusNotModified:
+		return true
+	}
+	return false
+}
+
+// decompress decodes the response body by its Content-Encoding, replacing the
+// response's body with the decoded copy. The header is kept so the encoding
+// can still be checked. Compression is nil for responses that are not
+// encoded or have no body to decode. Bodies with a coding no encoding is
+// registered for are kept encoded.
+func decompress(res *http.Response, body []byte) ([]byte, *Compression, error) {
+	codings := contentCodings(res.Header.Get("Content-Encoding"))
+	if len(codings) == 0 || len(body) == 0 || bodyless(res) {
+		return body, nil, nil
+	}
+
+	compression := &Compression{Encoding: strings.Join(codings, ", "), Size: len(body)}
+	readers := make([]Encoding, len(codings))
+	for i, coding := range codings {
+		e, err := lookupEncoding(coding)
+		if err != nil {
+			return body, compression, nil
+		}
+		readers[i] = e
+	}
+
+	decoded := body
+	for i := len(codings) - 1; i >= 0; i-- {
+		r, err := readers[i].NewReader(bytes.NewReader(decoded))
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot decode %s response: %s", codings[i], err)
+		}
+		decoded, err = ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot decode %s response: %s", codings[i], err)
+		}
+	}
+
+	res.Body = ioutil.NopCloser(bytes.NewReader(decoded))
+	compression.DecodedSize = len(decoded)
+	return decoded, compression, nil
+}
+
+// compressRequest compresses the request body with the content coding and
+// sets the request's Content-Encoding. The compressed body is returned for
+// signing.
+func compressRequest(req *http.Request, encoding string, body []byte) ([]byte, error) {
+	if encoding == "" || len(body) == 0 {
+		return body, nil
+	}
+
+	compressed, err := compress(encoding, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Body = ioutil.NopCloser(bytes.NewReader(compressed))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(compressed)), nil
+	}
+	req.ContentLength = int64(len(compressed))
+	req.Header.Set("Content-Encoding", encoding)
+
+	return compressed, nil
+}
+
+// AcceptEncoding sends Accept-Encoding with the codings, such as gzip or br,
+// for the test and sub-tests created after it. The response is then decoded
+// by the test instead of the transport, so its Content-Encoding can be
+// checked and its sizes are reported in Test.Compression.
+func (t *Test) AcceptEncoding(encodings ...string) *Test {
+	t.acceptEncoding = strings.Join(encodings, ", ")
+	return t
+}
+
+// AcceptEncoding sends Accept-Encoding with the codings, such as gzip or br,
+// so the response's Content-Encoding can be checked.
+func (e *EndpointTest) AcceptEncoding(encodings ...string) *EndpointTest {
+	e.acceptEncoding = strings.Join(encodings, ", ")
+	return e
+}
+
+// setAcceptEncoding sets the request's Accept-Encoding, unless a header sets
+// it already.
+func setAcceptEncoding(req *http.Request, encoding string) {
+	if encoding != "" && req.Header.Get("Accept-Encoding") == "" {
+		req.Header.Set("Accept-Encoding", encoding)
+	}
+}
+
+// CompressRequest compresses the request bodies of the test and of sub-tests
+// created after it with the registered encoding and sets their
+// Content-Encoding.
+func (t *Test) CompressRequest(encoding string) *Test {
+	if _, err := lookupEncoding(encoding); err != nil {
+		t.Error = err
+		return t
+	}
+
+	t.requestEncoding = encoding
+	return t
+}
+
+// CompressRequest compresses the request body with the registered encoding
+// and sets its Content-Encoding.
+func (e *EndpointTest) CompressRequest(encoding string) *EndpointTest {
+	if _, err := lookupEncoding(encoding); err != nil {
+		e.Error = err
+		return e
+	}
+
+	e.requestEncoding = encoding
+	return e
+}
+
+func checkContentEncoding(res *http.Response, encoding string) error {
+	actual := strings.Join(contentCodings(res.Header.Get("Content-Encoding")), ", ")
+	if strings.EqualFold(actual, encoding) {
+		return nil
+	}
+
+	if encoding == "" {
+		encoding = "none"
+	}
+	if actual == "" {
+		actual = "none"
+	}
+	return fmt.Errorf("expected Content-Encoding %s, actual %s", encoding, actual)
+}
+
+// MustContentEncoding sets the Test.Error if the response was not encoded
+// with the content coding. An empty encoding requires an unencoded response.
+func (t *Test) MustContentEncoding(encoding string) *Test {
+	if t.Error != nil {
+		return t
+	}
+
+	if t.Response == nil {
+		t.Error = errNoResponse
+		return t
+	}
+
+	t.Error = checkContentEncoding(t.Response, encoding)
+
+	return t
+}
+
+// MustContentEncoding sets the EndpointTest.Error if the response was not
+// encoded with the content coding. An empty encoding requires an unencoded
+// response.
+func (e *EndpointTest) MustContentEncoding(encoding string) *EndpointTest {
+	if e.Error != nil {
+		return e
+	}
+
+	if e.Response == nil {
+		e.Error = errNoResponse
+		return e
+	}
+
+	e.Error = checkContentEncoding(e.Response, encoding)
+
+	return e
+}
@@ -0,0 +1,238 @@
+package irest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// nopWriteCloser adapts a writer for encodings without a trailer.
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// newCompressingAPI responds with a JSON document encoded with the first
+// coding the request accepts, and echoes decoded request bodies.
+func newCompressingAPI() http.Handler {
+	doc := `{"items":["` + strings.Repeat("hat", 200) + `"]}`
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			if r.Header.Get("Content-Encoding") != "gzip" {
+				http.Error(w, "expected a gzip body", http.StatusUnsupportedMediaType)
+				return
+			}
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			io.Copy(w, zr)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		b := new(bytes.Buffer)
+		switch strings.Split(r.Header.Get("Accept-Encoding"), ",")[0] {
+		case "gzip":
+			w.Header().Set("Content-Encoding", "gzip")
+			zw := gzip.NewWriter(b)
+			zw.Write([]byte(doc))
+			zw.Close()
+		case "deflate":
+			w.Header().Set("Content-Encoding", "deflate")
+			zw := zlib.NewWriter(b)
+			zw.Write([]byte(doc))
+			zw.Close()
+		case "br":
+			w.Header().Set("Content-Encoding", "br")
+			b.WriteString("not really brotli")
+		default:
+			b.WriteString(doc)
+		}
+		w.Write(b.Bytes())
+	})
+}
+
+func TestAcceptEncoding(t *testing.T) {
+	for _, encoding := range []string{"gzip", "deflate"} {
+		var doc struct {
+			Items []string `json:"items"`
+		}
+		test := NewTest(encoding).Handler(newCompressingAPI()).AcceptEncoding(encoding, "identity").
+			Get("", "/items").
+			MustStatus(http.StatusOK).
+			MustContentEncoding(encoding).
+			ParseResponseBody(&doc)
+		if test.Error != nil {
+			t.Errorf("%s: %s", encoding, test.Error)
+			continue
+		}
+		if len(doc.Items) != 1 || len(doc.Items[0]) != 600 {
+			t.Errorf("%s: expected the decoded document, got %v", encoding, doc.Items)
+		}
+
+		c := test.Compression
+		if c == nil || c.Encoding != encoding || c.DecodedSize != 614 || c.Size >= c.DecodedSize {
+			t.Errorf("%s: expected compressed and decoded sizes, got %+v", encoding, c)
+		}
+	}
+
+	test := NewTest("identity").Handler(newCompressingAPI()).Get("", "/items").MustContentEncoding("")
+	if test.Error != nil {
+		t.Error(test.Error)
+	}
+	if test.Compression != nil {
+		t.Errorf("expected no compression for an unencoded response, got %+v", test.Compression)
+	}
+
+	test = NewTest("wrong encoding").Handler(newCompressingAPI()).AcceptEncoding("gzip").Get("", "/items").MustContentEncoding("deflate")
+	if test.Error == nil || test.Error.Error() != "expected Content-Encoding deflate, actual gzip" {
+		t.Errorf("expected a Content-Encoding error, got %v", test.Error)
+	}
+}
+
+func TestAcceptEncodingSubTests(t *testing.T) {
+	test := NewTest("sub-tests").Handler(newCompressingAPI())
+	a := test.NewTest("a").AcceptEncoding("gzip")
+	a.Get("", "/items").MustContentEncoding("gzip")
+	b := test.NewTest("b").Get("", "/items").MustContentEncoding("")
+	nested := a.NewTest("nested").Get("", "/items").MustContentEncoding("gzip")
+
+	for _, c := range []*Test{a, b, nested} {
+		if c.Error != nil {
+			t.Errorf("%s: %s", c.Name, c.Error)
+		}
+	}
+	if test.Header.Get("Accept-Encoding") != "" {
+		t.Errorf("expected no Accept-Encoding on the parent, got %s", test.Header.Get("Accept-Encoding"))
+	}
+}
+
+func TestUnregisteredEncoding(t *testing.T) {
+	test := NewTest("br").Handler(newCompressingAPI()).AcceptEncoding("br").Get("", "/items").
+		MustStatus(http.StatusOK).
+		MustContentEncoding("br")
+	if test.Error != nil {
+		t.Fatal(test.Error)
+	}
+	if string(test.responseBody) != "not really brotli" {
+		t.Errorf("expected the body kept encoded, got %q", test.responseBody)
+	}
+	if c := test.Compression; c == nil || c.Encoding != "br" || c.Size != 17 || c.DecodedSize != 0 {
+		t.Errorf("expected br compression without a decoded size, got %+v", c)
+	}
+
+	test = NewTest("compress br").CompressRequest("br")
+	if test.Error == nil {
+		t.Error("expected an error compressing with an unregistered encoding")
+	}
+}
+
+func TestRegisterEncoding(t *testing.T) {
+	RegisterEncoding("x-upper", Encoding{
+		NewWriter: func(w io.Writer) (io.WriteCloser, error) { return nopWriteCloser{w}, nil },
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			b, err := ioutil.ReadAll(r)
+			return ioutil.NopCloser(bytes.NewReader(bytes.ToLower(b))), err
+		},
+	})
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "x-upper, gzip")
+		zw := gzip.NewWriter(w)
+		zw.Write([]byte("HELLO"))
+		zw.Close()
+	})
+
+	test := NewTest("registered").Handler(handler).Get("", "/")
+	if test.Error != nil {
+		t.Fatal(test.Error)
+	}
+	if string(test.responseBody) != "hello" {
+		t.Errorf("expected codings decoded in reverse order, got %q", test.responseBody)
+	}
+	if test.Compression.Encoding != "x-upper, gzip" {
+		t.Errorf("expected both codings, got %s", test.Compression.Encoding)
+	}
+}
+
+func TestCompressRequest(t *testing.T) {
+	test := NewTest("compressed upload").Handler(newCompressingAPI()).CompressRequest("gzip")
+	sub := test.NewTest("upload").Post("", "/upload", Raw("text/plain", []byte("a hat")))
+	if sub.Error != nil {
+		t.Fatal(sub.Error)
+	}
+	sub.MustStatus(http.StatusOK)
+	if sub.Error != nil {
+		t.Fatal(sub.Error)
+	}
+	if string(sub.responseBody) != "a hat" {
+		t.Errorf("expected the decompressed body echoed, got %q", sub.responseBody)
+	}
+	if string(sub.requestBody) != "a hat" {
+		t.Errorf("expected the uncompressed body kept for reports, got %q", sub.requestBody)
+	}
+
+	endpoint := Endpoint{Path: "/upload", Method: http.MethodPost}
+	e := endpoint.Use("", Raw("text/plain", []byte("a scarf"))).In(NewTest("endpoint").Handler(newCompressingAPI())).CompressRequest("gzip")
+	e.Do().MustStatus(http.StatusOK)
+	if e.Error != nil {
+		t.Fatal(e.Error)
+	}
+	if string(e.responseBody) != "a scarf" {
+		t.Errorf("expected the decompressed body echoed, got %q", e.responseBody)
+	}
+}
+
+func TestCompressionString(t *testing.T) {
+	c := Compression{Encoding: "gzip", Size: 250, DecodedSize: 1000}
+	if s := c.String(); s != "gzip 250 bytes, 1000 decoded (25%)" {
+		t.Errorf("unexpected compression string %s", s)
+	}
+}
+
+func TestDecompressWithoutBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		switch r.URL.Path {
+		case "/empty":
+			w.WriteHeader(http.StatusNoContent)
+		case "/cached":
+			w.WriteHeader(http.StatusNotModified)
+		default:
+			w.Header().Set("Content-Length", "120")
+		}
+	}))
+	defer server.Close()
+
+	var bodylessTests = []struct {
+		method string
+		path   string
+		status int
+	}{
+		{http.MethodHead, "/items", http.StatusOK},
+		{http.MethodGet, "/empty", http.StatusNoContent},
+		{http.MethodGet, "/cached", http.StatusNotModified},
+	}
+
+	for _, tt := range bodylessTests {
+		test := NewTest(tt.path).AcceptEncoding("gzip").Request(tt.method, server.URL, tt.path, nil).
+			MustStatus(tt.status).
+			MustContentEncoding("gzip")
+		if test.Error != nil {
+			t.Errorf("%s %s: %s", tt.method, tt.path, test.Error)
+		}
+		if test.Compression != nil {
+			t.Errorf("%s %s: expected no compression for a response without a body, got %+v", tt.method, tt.path, test.Compression)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package irest
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// DialFunc opens connections for requests, as http.Transport.DialContext.
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// unixSockets maps the hosts standing in for Unix sockets in requests to
+// the socket paths.
+var unixSockets sync.Map
+
+// baseTransport is the transport tests start from when changing theirs. It
+// dials Unix sockets for unix:// base URLs.
+var baseTransport = newBaseTransport()
+
+func newBaseTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = unixDial(transport.DialContext)
+	return transport
+}
+
+// unixDial wraps a dial function to dial the Unix socket of hosts standing
+// in for sockets.
+func unixDial(dial DialFunc) DialFunc {
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		if socket, ok := unixSockets.Load(host); ok {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket.(string))
+		}
+		return dial(ctx, network, addr)
+	}
+}
+
+// unixHost returns the host standing in for a Unix socket, one per socket so
+// each has its own connection pool.
+func unixHost(socket string) string {
+	h := fnv.New64a()
+	h.Write([]byte(socket))
+	host := fmt.Sprintf("unix-%x.sock", h.Sum64())
+	unixSockets.Store(host, socket)
+	return host
+}
+
+// unixRequest rewrites a request to a unix:// URL, as in
+// unix:///run/app.sock:/api/things, into an HTTP request to the host standing
+// in for the socket, made with a client that can dial it.
+func unixRequest(client *http.Client, req *http.Request) (*http.Client, *http.Request, error) {
+	socket, path := req.URL.Path, "/"
+	if i := strings.Index(socket, ":"); i >= 0 {
+		socket, path = socket[:i], socket[i+1:]
+	}
+	if socket == "" {
+		return nil, nil, fmt.Errorf("unix URL %s has no socket path", req.URL)
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	rewritten := req.Clone(req.Context())
+	rewritten.URL.Scheme = "http"
+	rewritten.URL.Host = unixHost(socket)
+	rewritten.URL.Path = path
+	rewritten.URL.RawPath = ""
+	rewritten.Host = "localhost"
+
+	if client.Transport == nil {
+		c := *client
+		c.Transport = baseTransport
+		client = &c
+	}
+
+	return client, rewritten, nil
+}
+
+// configureTransport changes the transport of the test and of sub-tests
+// created after it.
+func (t *Test) configureTransport(configure func(*http.Transport) error) *Test {
+	if t.Error != nil {
+		return t
+	}
+
+	transport, err := t.httpTransport()
+	if err != nil {
+		t.Error = err
+		return t
+	}
+
+	if err := configure(transport); err != nil {
+		t.Error = err
+		return t
+	}
+
+	t.setTransport(transport)
+	return t
+}
+
+// Dialer opens the connections of the test and of sub-tests created after
+// it with the dial function.
+func (t *Test) Dialer(dial DialFunc) *Test {
+	return t.configureTransport(func(transport *http.Transport) error {
+		transport.DialContext = unixDial(dial)
+		return nil
+	})
+}
+
+// UnixSocket sends all requests of the test and of sub-tests created after
+// it to the Unix socket, whatever the host of their URL.
+func (t *Test) UnixSocket(path string) *Test {
+	return t.Dialer(func(ctx context.Context, _, _ string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "unix", path)
+	})
+}
+
+// Resolve connects to the address, such as 127.0.0.1:8443, instead of the
+// host and port, such as api.example.com:443, as curl's --resolve does. The
+// URL keeps its host, so virtual hosts and TLS server names still apply.
+func (t *Test) Resolve(hostPort, address string) *Test {
+	if _, _, err := net.SplitHostPort(hostPort); err != nil {
+		t.Error = fmt.Errorf("resolve %s: %s", hostPort, err)
+		return t
+	}
+
+	return t.configureTransport(func(transport *http.Transport) error {
+		dial := transport.DialContext
+		if dial == nil {
+			dial = (&net.Dialer{}).DialContext
+		}
+		transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if strings.EqualFold(addr, hostPort) {
+				addr = address
+			}
+			return dial(ctx, network, addr)
+		}
+		return nil
+	})
+}
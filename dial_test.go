@@ -0,0 +1,147 @@
+package irest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+// hostEcho responds with the host and path of the request.
+var hostEcho = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintf(w, `{"host":%q,"path":%q}`, r.Host, r.URL.Path)
+})
+
+type hostPath struct {
+	Host string `json:"host"`
+	Path string `json:"path"`
+}
+
+// newUnixAPI serves hostEcho on a Unix socket.
+func newUnixAPI(t *testing.T) (string, func()) {
+	dir, err := os.MkdirTemp("", "irest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	socket := filepath.Join(dir, "app.sock")
+
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &http.Server{Handler: hostEcho}
+	go server.Serve(l)
+
+	return socket, func() {
+		server.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestUnixBaseURL(t *testing.T) {
+	socket, stop := newUnixAPI(t)
+	defer stop()
+
+	baseURL := "unix://" + socket + ":/api"
+
+	var actual hostPath
+	test := NewTest("unix").Get(baseURL, "/things?page=1").MustStatus(http.StatusOK).ParseResponseBody(&actual)
+	if test.Error != nil {
+		t.Fatal(test.Error)
+	}
+	if actual.Path != "/api/things" || actual.Host != "localhost" {
+		t.Errorf("expected /api/things on localhost, got %+v", actual)
+	}
+
+	get := &Endpoint{Path: "/things/%d", Method: http.MethodGet}
+	e := get.Use(baseURL, nil, 1).Do().MustStatus(http.StatusOK).ParseResponseBody(&actual)
+	if e.Error != nil {
+		t.Fatal(e.Error)
+	}
+	if actual.Path != "/api/things/1" {
+		t.Errorf("expected /api/things/1, got %+v", actual)
+	}
+
+	withMiddleware := NewTest("middleware").AddMiddleware(RequestID("")).Get(baseURL, "/").MustStatus(http.StatusOK)
+	if withMiddleware.Error != nil {
+		t.Fatal(withMiddleware.Error)
+	}
+
+	missing := NewTest("missing").Get("unix://", "/")
+	if missing.Error == nil {
+		t.Error("expected an error for a unix URL without a socket")
+	}
+}
+
+func TestUnixSocket(t *testing.T) {
+	socket, stop := newUnixAPI(t)
+	defer stop()
+
+	var actual hostPath
+	test := NewTest("socket").UnixSocket(socket)
+	test.NewTest("get").Get("http://admin.local", "/status").MustStatus(http.StatusOK).ParseResponseBody(&actual)
+	if err := test.Tests[0].Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if actual.Host != "admin.local" || actual.Path != "/status" {
+		t.Errorf("expected admin.local/status, got %+v", actual)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	server := httptest.NewServer(hostEcho)
+	defer server.Close()
+
+	var actual hostPath
+	test := NewTest("resolve").Resolve("api.example.test:80", server.Listener.Addr().String())
+	test.Get("http://api.example.test", "/things").MustStatus(http.StatusOK).ParseResponseBody(&actual)
+	if test.Error != nil {
+		t.Fatal(test.Error)
+	}
+
+	if actual.Host != "api.example.test" {
+		t.Errorf("expected the virtual host to be kept, got %+v", actual)
+	}
+
+	if NewTest("invalid").Resolve("api.example.test", "127.0.0.1:80").Error == nil {
+		t.Error("expected an error for a host without a port")
+	}
+}
+
+func TestResolveTLS(t *testing.T) {
+	server := httptest.NewTLSServer(hostEcho)
+	defer server.Close()
+
+	test := NewTest("resolve tls").AddRootCA(serverCAPEM(server)).Resolve("example.com:443", server.Listener.Addr().String())
+	test.Get("https://example.com", "/").MustStatus(http.StatusOK).MustPeerCertificate(CertDNSName("example.com"))
+	if test.Error != nil {
+		t.Fatal(test.Error)
+	}
+}
+
+func TestDialer(t *testing.T) {
+	server := httptest.NewServer(hostEcho)
+	defer server.Close()
+
+	var dials int64
+	test := NewTest("dialer").Dialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		atomic.AddInt64(&dials, 1)
+		var d net.Dialer
+		return d.DialContext(ctx, network, addr)
+	})
+	test.Get(server.URL, "/").MustStatus(http.StatusOK)
+	if test.Error != nil {
+		t.Fatal(test.Error)
+	}
+
+	if atomic.LoadInt64(&dials) != 1 {
+		t.Errorf("expected the dialer to be used once, got %d", dials)
+	}
+}
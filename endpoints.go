@@ -2,7 +2,6 @@ package irest
 
 import (
 	"bytes"
-	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -46,23 +45,39 @@ type EndpointTest struct {
 	Payload interface{}
 
 	Client  *http.Client
+	Signer  Signer
 	Cookies []*http.Cookie
 	Header  *http.Header
 
-	Duration int64
-	Response *http.Response
-	Error    error
+	Duration    time.Duration
+	Timing      Timing
+	Compression *Compression
+	Request     *http.Request
+	Response    *http.Response
+	Error       error
+
+	// LatencyError is set when the response was slower than allowed.
+	LatencyError error
+
+	// Skipped endpoint tests do not make requests.
+	Skipped bool
+
+	requestEncoding string
+	acceptEncoding  string
+	requestBody     []byte
+	responseBody    []byte
 }
 
 // Build constructs a usable endpoint with the full URL from the baseURL,
-// relative path, and variables.
+// relative path, and variables. The endpoint test has a plain client until
+// it is bound to a test with In, or built with Test.Use instead.
 func (e *Endpoint) Use(baseURL string, payload interface{}, v ...interface{}) *EndpointTest {
 	et := &EndpointTest{
-		Path:   e.Path,
-		Method: e.Method,
+		Path:    e.Path,
+		Method:  e.Method,
 		Payload: payload,
-		Header: &http.Header{},
-		Client: &http.Client{},
+		Header:  &http.Header{},
+		Client:  &http.Client{},
 	}
 
 	if strings.HasSuffix(baseURL, "/") {
@@ -79,9 +94,28 @@ func (e *Endpoint) Use(baseURL string, payload interface{}, v ...interface{}) *E
 	return et
 }
 
+// Use builds an endpoint test, as Endpoint.Use, bound to the test so its
+// request is made with the test's client, middleware, signer and request
+// compression.
+func (t *Test) Use(e *Endpoint, baseURL string, payload interface{}, v ...interface{}) *EndpointTest {
+	return e.Use(baseURL, payload, v...).In(t)
+}
+
+// configuresRequests reports whether the test changes how requests are made,
+// with a transport, middleware, a signer, request compression or accepted
+// encodings, which
+// endpoint tests only get when bound to it.
+func (t *Test) configuresRequests() bool {
+	return t.transport != nil || len(t.middleware) > 0 || t.Signer != nil || t.requestEncoding != "" || t.acceptEncoding != ""
+}
+
 // UseHeader uses a previously saved header value by name as a header with the
 // provided name.
 func (e *EndpointTest) UseHeader(savedName, name string) *EndpointTest {
+	if e.Parent == nil {
+		e.Error = fmt.Errorf("header saved as %s needs a test, must bind the endpoint test with In or Test.Use", savedName)
+		return e
+	}
 	savedValue, ok := e.Parent.savedValues[savedName]
 	if !ok {
 		e.Error = fmt.Errorf("header not found saved as %s", savedName)
@@ -93,6 +127,10 @@ func (e *EndpointTest) UseHeader(savedName, name string) *EndpointTest {
 
 // UseCookie adds to the slice of cookies to be included in the request.
 func (e *EndpointTest) UseCookie(savedName, name string) *EndpointTest {
+	if e.Parent == nil {
+		e.Error = fmt.Errorf("cookie saved as %s needs a test, must bind the endpoint test with In or Test.Use", savedName)
+		return e
+	}
 	savedValue, ok := e.Parent.savedValues[savedName]
 	if !ok {
 		e.Error = fmt.Errorf("cookie not found saved as %s", savedName)
@@ -114,6 +152,11 @@ func (e *EndpointTest) SaveHeader(name, savedName string) *EndpointTest {
 		return e
 	}
 
+	if e.Parent == nil {
+		e.Error = fmt.Errorf("no test to save %s in, must bind the endpoint test with In or Test.Use", savedName)
+		return e
+	}
+
 	if value := e.Response.Header.Get(name); value != "" {
 		e.Parent.savedValues[savedName] = value
 		return e
@@ -131,37 +174,77 @@ func (e *EndpointTest) SaveHeader(name, savedName string) *EndpointTest {
 	return e
 }
 
-// Do executes the request.
+// Skip marks the endpoint test as skipped so no request is made for it.
+func (e *EndpointTest) Skip() *EndpointTest {
+	e.Skipped = true
+	return e
+}
+
+// Do executes the request unless the endpoint test is skipped.
 func (e *EndpointTest) Do() *EndpointTest {
-	b := new(bytes.Buffer)
-	if e.Payload != nil {
-		if err := json.NewEncoder(b).Encode(e.Payload); err != nil {
-			e.Error = err
-			return e
-		}
+	if e.Skipped {
+		return e
 	}
 
-	req, err := http.NewRequest(e.Method, e.URL, b)
-	if err != nil {
+	if err := e.newRequest(); err != nil {
 		e.Error = err
 		return e
 	}
 
-	req.Header = *e.Header
+	return e.send()
+}
+
+// newRequest builds the request to make from the endpoint test.
+func (e *EndpointTest) newRequest() error {
+	contentType, body, err := encodeBody(e.Payload)
+	if err != nil {
+		return err
+	}
+
+	e.requestBody = body
+	req, err := http.NewRequest(e.Method, e.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	e.Request = req
+
+	req.Header = e.Header.Clone()
+	setBodyHeaders(req.Header, e.Payload, contentType)
+	setAcceptEncoding(req, e.acceptEncoding)
 	for _, c := range e.Cookies {
 		req.AddCookie(c)
 	}
 
-	startTime := time.Now()
-	res, err := e.Client.Do(req)
+	if body, err = compressRequest(req, e.requestEncoding, body); err != nil {
+		return err
+	}
+
+	if e.Signer != nil {
+		return e.Signer.Sign(req, body)
+	}
+
+	return nil
+}
+
+// send makes the built request and records the response.
+func (e *EndpointTest) send() *EndpointTest {
+	res, body, timing, err := send(e.Client, e.Request)
+	e.Timing = timing
+	e.Duration = timing.Total
 	if err != nil {
 		e.Error = err
 		return e
 	}
-	reqDuration := time.Since(startTime)
-	e.Duration = reqDuration.Nanoseconds() / int64(time.Millisecond)
+
+	if body, e.Compression, err = decompress(res, body); err != nil {
+		e.Error = err
+		return e
+	}
 
 	e.Response = res
+	e.responseBody = body
+
+	e.checkLatencyBudget()
 
 	return e
 }
@@ -169,7 +252,12 @@ func (e *EndpointTest) Do() *EndpointTest {
 // MustStatus sets the EndpointTest.Error if the status code is not the expected
 // value. An HTTP request must have been made prior to this function call.
 func (e *EndpointTest) MustStatus(statusCode int) *EndpointTest {
-	if e.Error != nil {
+	if e.Error != nil || e.Skipped {
+		return e
+	}
+
+	if e.Response == nil {
+		e.Error = fmt.Errorf("expected status code response of %d, actual no response", statusCode)
 		return e
 	}
 
@@ -180,8 +268,8 @@ func (e *EndpointTest) MustStatus(statusCode int) *EndpointTest {
 	return e
 }
 
-// ParseResponseBody parses the HTTP response body from json
-// to a provided interface.
+// ParseResponseBody parses the HTTP response body to a provided interface
+// with the decoder for its Content-Type: JSON, XML, form or text.
 func (t *EndpointTest) ParseResponseBody(result interface{}) *EndpointTest {
 	if t.Response == nil || t.Response.Body == nil {
 		t.Error = fmt.Errorf("need response body to parse")
@@ -197,7 +285,7 @@ func (t *EndpointTest) ParseResponseBody(result interface{}) *EndpointTest {
 		return t
 	}
 
-	if err := json.Unmarshal(resultBody, result); err != nil {
+	if err := decodeBody(t.Response.Header.Get("Content-Type"), resultBody, result); err != nil {
 		t.Error = err
 		return t
 	}
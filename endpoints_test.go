@@ -1,6 +1,7 @@
 package irest
 
 import (
+	"net/http"
 	"testing"
 )
 
@@ -64,3 +65,51 @@ func TestEndpointParseResponseBodyEmptyResponse(t *testing.T) {
 		t.Errorf("expected an error, but did not get one")
 	}
 }
+
+func TestEndpointMustStatusWithoutResponse(t *testing.T) {
+	get := &Endpoint{Path: "/tests", Method: http.MethodGet}
+
+	skipped := get.Use(api.URL, nil).Skip().Do().MustStatus(http.StatusOK)
+	if skipped.Error != nil || skipped.Response != nil {
+		t.Errorf("expected skipped endpoint test without a request or error, got %v, %v", skipped.Response, skipped.Error)
+	}
+
+	unsent := get.Use(api.URL, nil).MustStatus(http.StatusOK)
+	if unsent.Error == nil {
+		t.Error("expected error checking the status without a response")
+	}
+}
+
+func TestNewEndpointsTestBinds(t *testing.T) {
+	get := &Endpoint{Path: "/echo", Method: http.MethodGet}
+	test := NewTest("bind").AddMiddleware(RequestID("X-Request-ID")).AddHeader("name", "shared")
+
+	var echo echoed
+	unsent := get.Use(api.URL, nil)
+	scenario := test.NewEndpointsTest("scenario", test.Use(get, api.URL, nil), unsent)
+	unsent.Do().MustStatus(http.StatusOK).ParseResponseBody(&echo)
+	if unsent.Error != nil {
+		t.Fatal(unsent.Error)
+	}
+
+	if unsent.Parent != scenario {
+		t.Errorf("expected endpoint test bound to %s, actual %v", scenario.Name, unsent.Parent)
+	}
+	if echo.RequestID == "" {
+		t.Errorf("expected request ID from the test's middleware, actual none")
+	}
+	if echo.Name != "shared" {
+		t.Errorf("expected name header shared, actual %q", echo.Name)
+	}
+
+	sent := get.Use(api.URL, nil).Do().MustStatus(http.StatusOK)
+	test.NewEndpointsTest("sent", sent)
+	if sent.Error == nil {
+		t.Errorf("expected error for endpoint test sent before being bound, actual none")
+	}
+
+	unbound := get.Use(api.URL, nil).UseHeader("AUTH", "Authorization")
+	if unbound.Error == nil {
+		t.Errorf("expected error using a saved header without a test, actual none")
+	}
+}
@@ -0,0 +1,66 @@
+package irest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+)
+
+// handlerHost is the host of requests to a handler made without a base URL.
+const handlerHost = "handler.local"
+
+// handlerTransport serves requests with an http.Handler in process instead
+// of sending them over the network.
+type handlerTransport struct {
+	handler http.Handler
+}
+
+// RoundTrip serves the request with the handler and returns the recorded
+// response. A panic in the handler is returned as an error.
+func (h handlerTransport) RoundTrip(req *http.Request) (res *http.Response, err error) {
+	r := req.Clone(req.Context())
+	if r.URL.Scheme == "" {
+		r.URL.Scheme = "http"
+	}
+	if r.URL.Host == "" {
+		r.URL.Host = handlerHost
+	}
+	if r.Host == "" {
+		r.Host = r.URL.Host
+	}
+	if r.Body == nil {
+		r.Body = http.NoBody
+	}
+	r.RequestURI = r.URL.RequestURI()
+	r.RemoteAddr = "192.0.2.1:1234"
+	r.Proto, r.ProtoMajor, r.ProtoMinor = "HTTP/1.1", 1, 1
+	defer r.Body.Close()
+
+	defer func() {
+		if p := recover(); p != nil {
+			res, err = nil, fmt.Errorf("handler panic serving %s %s: %v", req.Method, req.URL.Path, p)
+		}
+	}()
+
+	rec := httptest.NewRecorder()
+	h.handler.ServeHTTP(rec, r)
+
+	res = rec.Result()
+	res.Request = req
+	return res, nil
+}
+
+// Handler serves the requests of the test and of sub-tests created after it
+// with the handler, in process and without a network listener. Base URLs are
+// kept for their paths, and the host when set, so the same suite can run
+// against the handler or a real server by changing only the base URL.
+// Responses are complete once the handler returns. Endpoint tests are served
+// by the handler once bound to the test, by Test.Use, In or NewEndpointsTest.
+func (t *Test) Handler(h http.Handler) *Test {
+	if t.Client == nil {
+		t.Client = &http.Client{}
+	}
+
+	t.setTransport(handlerTransport{handler: h})
+	return t
+}
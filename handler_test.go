@@ -0,0 +1,160 @@
+package irest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type thing struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// newThingsHandler stores things in memory.
+func newThingsHandler() http.Handler {
+	var mu sync.Mutex
+	things := []thing{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/things", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch r.Method {
+		case http.MethodPost:
+			var th thing
+			if err := json.NewDecoder(r.Body).Decode(&th); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			th.ID = len(things) + 1
+			things = append(things, th)
+			http.SetCookie(w, &http.Cookie{Name: "last", Value: th.Name})
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(th)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(things)
+		}
+	})
+	mux.HandleFunc("/last", func(w http.ResponseWriter, r *http.Request) {
+		c, err := r.Cookie("last")
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(c.Value))
+	})
+	mux.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	return mux
+}
+
+// runThingsSuite runs the same checks in process or against a server.
+func runThingsSuite(t *testing.T, test *Test, baseURL string) {
+	created := &thing{}
+	test.NewTest("create").Post(baseURL, "/things", thing{Name: "first"}).
+		MustStatus(http.StatusCreated).
+		MustContentType("application/json").
+		ParseResponseBody(created)
+
+	list := []thing{}
+	test.NewTest("list").Get(baseURL, "/things").MustStatus(http.StatusOK).ParseResponseBody(&list)
+
+	get := &Endpoint{Path: "/last", Method: http.MethodGet}
+	e := test.Use(get, baseURL, nil).Do().MustStatus(http.StatusOK)
+
+	for _, c := range test.Tests {
+		if c.Error != nil {
+			t.Errorf("%s: %s", c.Name, c.Error)
+		}
+	}
+	if e.Error != nil {
+		t.Error(e.Error)
+	}
+
+	if created.ID != 1 || len(list) != 1 || list[0].Name != "first" {
+		t.Errorf("unexpected things %+v, %+v", created, list)
+	}
+
+	if string(e.responseBody) != "first" {
+		t.Errorf("expected the cookie jar to work, got %q", e.responseBody)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	runThingsSuite(t, NewTest("in process").Handler(newThingsHandler()), "http://api.test")
+}
+
+func TestHandlerEndpointTests(t *testing.T) {
+	create := &Endpoint{Path: "/things", Method: http.MethodPost}
+	list := &Endpoint{Path: "/things", Method: http.MethodGet}
+
+	test := NewTest("endpoints").Handler(newThingsHandler())
+	created := test.Use(create, "http://api.test", thing{Name: "used"}).Do().MustStatus(http.StatusCreated)
+	unsent := list.Use("http://api.test", nil)
+	scenario := test.NewEndpointsTest("scenario", created, unsent)
+
+	things := []thing{}
+	unsent.Do().MustStatus(http.StatusOK).ParseResponseBody(&things)
+
+	for _, e := range scenario.EndpointTests {
+		if e.Error != nil {
+			t.Errorf("%s %s: %s", e.Method, e.URL, e.Error)
+		}
+	}
+	if len(things) != 1 || things[0].Name != "used" {
+		t.Errorf("expected the handler to serve both endpoint tests, got %+v", things)
+	}
+}
+
+func TestHandlerSameSuiteOverNetwork(t *testing.T) {
+	server := httptest.NewServer(newThingsHandler())
+	defer server.Close()
+
+	runThingsSuite(t, NewTest("network"), server.URL)
+}
+
+func TestHandlerWithoutBaseURL(t *testing.T) {
+	test := NewTest("no base").Handler(newThingsHandler())
+	test.Post("", "/things", thing{Name: "relative"}).MustStatus(http.StatusCreated)
+	if test.Error != nil {
+		t.Fatal(test.Error)
+	}
+
+	if host := test.Response.Request.URL.Host; host != "" && host != handlerHost {
+		t.Errorf("unexpected host %s", host)
+	}
+}
+
+func TestHandlerMiddleware(t *testing.T) {
+	var seen string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = r.Header.Get("X-Request-ID")
+	})
+
+	test := NewTest("middleware").AddMiddleware(RequestID("")).Handler(handler)
+	test.Get("http://api.test", "/").MustStatus(http.StatusOK)
+	if test.Error != nil {
+		t.Fatal(test.Error)
+	}
+
+	if seen == "" {
+		t.Error("expected middleware to run before the handler")
+	}
+}
+
+func TestHandlerPanic(t *testing.T) {
+	test := NewTest("panic").Handler(newThingsHandler())
+	test.Get("http://api.test", "/panic")
+
+	if test.Error == nil || !strings.Contains(test.Error.Error(), "handler panic serving GET /panic: boom") {
+		t.Errorf("expected the panic as an error, got %v", test.Error)
+	}
+}
@@ -0,0 +1,159 @@
+package irest
+
+import (
+	"math"
+	"math/bits"
+	"time"
+)
+
+// histogramSubBits sets the precision of a Histogram. Values are kept to the
+// 7 most significant bits, a relative error of under 1/64.
+const (
+	histogramSubBits  = 7
+	histogramSubCount = 1 << histogramSubBits
+	histogramHalf     = histogramSubCount / 2
+)
+
+// Histogram records latencies in log-linear buckets, in the style of HDR
+// histograms, so percentiles of any number of requests can be read with a
+// bounded relative error and constant memory. Values have microsecond
+// resolution. A Histogram is not safe for concurrent use.
+type Histogram struct {
+	counts []int64
+	count  int64
+	sum    time.Duration
+	min    time.Duration
+	max    time.Duration
+}
+
+// NewHistogram creates an empty histogram.
+func NewHistogram() *Histogram {
+	return &Histogram{}
+}
+
+// bucketIndex returns the bucket of a value in microseconds. Values below
+// histogramSubCount have a bucket each, larger values share buckets with
+// others of the same magnitude and top bits.
+func bucketIndex(v uint64) int {
+	if v < histogramSubCount {
+		return int(v)
+	}
+	shift := bits.Len64(v) - histogramSubBits
+	sub := v >> uint(shift)
+	return histogramSubCount + (shift-1)*histogramHalf + int(sub-histogramHalf)
+}
+
+// bucketHighest returns the highest value in microseconds that falls into the
+// bucket.
+func bucketHighest(i int) uint64 {
+	if i < histogramSubCount {
+		return uint64(i)
+	}
+	k := i - histogramSubCount
+	shift := uint(k/histogramHalf + 1)
+	sub := uint64(k%histogramHalf + histogramHalf)
+	return (sub+1)<<shift - 1
+}
+
+// Record adds a latency to the histogram. Negative values are recorded as
+// zero.
+func (h *Histogram) Record(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+
+	i := bucketIndex(uint64(d / time.Microsecond))
+	if i >= len(h.counts) {
+		counts := make([]int64, i+1)
+		copy(counts, h.counts)
+		h.counts = counts
+	}
+	h.counts[i]++
+
+	if h.count == 0 || d < h.min {
+		h.min = d
+	}
+	if d > h.max {
+		h.max = d
+	}
+	h.count++
+	h.sum += d
+}
+
+// Merge adds all values recorded by another histogram.
+func (h *Histogram) Merge(other *Histogram) {
+	if other == nil || other.count == 0 {
+		return
+	}
+
+	if len(other.counts) > len(h.counts) {
+		counts := make([]int64, len(other.counts))
+		copy(counts, h.counts)
+		h.counts = counts
+	}
+	for i, c := range other.counts {
+		h.counts[i] += c
+	}
+
+	if h.count == 0 || other.min < h.min {
+		h.min = other.min
+	}
+	if other.max > h.max {
+		h.max = other.max
+	}
+	h.count += other.count
+	h.sum += other.sum
+}
+
+// Count returns the number of recorded values.
+func (h *Histogram) Count() int64 {
+	return h.count
+}
+
+// Min returns the smallest recorded value.
+func (h *Histogram) Min() time.Duration {
+	return h.min
+}
+
+// Max returns the largest recorded value.
+func (h *Histogram) Max() time.Duration {
+	return h.max
+}
+
+// Mean returns the average of the recorded values.
+func (h *Histogram) Mean() time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	return h.sum / time.Duration(h.count)
+}
+
+// Percentile returns the value at or below which p percent of the recorded
+// values fall, as the highest value of its bucket, capped to the maximum.
+func (h *Histogram) Percentile(p float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+
+	rank := int64(math.Ceil(p / 100 * float64(h.count)))
+	if rank < 1 {
+		rank = 1
+	}
+
+	var seen int64
+	for i, c := range h.counts {
+		seen += c
+		if seen >= rank {
+			d := time.Duration(bucketHighest(i)) * time.Microsecond
+			if d > h.max {
+				d = h.max
+			}
+			if d < h.min {
+				d = h.min
+			}
+			return d
+		}
+	}
+
+	return h.max
+}
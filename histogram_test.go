@@ -0,0 +1,73 @@
+package irest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBucketIndexRoundTrip(t *testing.T) {
+	for _, v := range []uint64{0, 1, 127, 128, 129, 255, 256, 1000, 123456, 1 << 40} {
+		i := bucketIndex(v)
+		if high := bucketHighest(i); high < v {
+			t.Errorf("expected bucket %d of %d to reach at least %d, got %d", i, v, v, high)
+		}
+		if bucketIndex(bucketHighest(i)) != i {
+			t.Errorf("expected highest value of bucket %d to be in the same bucket", i)
+		}
+	}
+
+	if bucketIndex(255)+1 != bucketIndex(256) {
+		t.Error("expected buckets to be contiguous across magnitudes")
+	}
+}
+
+func TestHistogramPercentiles(t *testing.T) {
+	h := NewHistogram()
+	for i := 1; i <= 1000; i++ {
+		h.Record(time.Duration(i) * time.Millisecond)
+	}
+
+	if h.Count() != 1000 || h.Min() != time.Millisecond || h.Max() != time.Second {
+		t.Errorf("unexpected count %d, min %s and max %s", h.Count(), h.Min(), h.Max())
+	}
+
+	if h.Mean() != 500500*time.Microsecond {
+		t.Errorf("expected mean of 500.5ms, got %s", h.Mean())
+	}
+
+	var percentileTests = []struct {
+		p        float64
+		expected time.Duration
+	}{
+		{50, 500 * time.Millisecond},
+		{95, 950 * time.Millisecond},
+		{99, 990 * time.Millisecond},
+		{100, time.Second},
+	}
+
+	for _, pt := range percentileTests {
+		actual := h.Percentile(pt.p)
+		relative := float64(actual-pt.expected) / float64(pt.expected)
+		if relative < 0 || relative > 1.0/64 {
+			t.Errorf("expected p%.0f within 1/64 above %s, got %s", pt.p, pt.expected, actual)
+		}
+	}
+}
+
+func TestHistogramMerge(t *testing.T) {
+	a, b := NewHistogram(), NewHistogram()
+	a.Record(10 * time.Millisecond)
+	b.Record(time.Millisecond)
+	b.Record(time.Second)
+
+	a.Merge(b)
+	a.Merge(nil)
+
+	if a.Count() != 3 || a.Min() != time.Millisecond || a.Max() != time.Second {
+		t.Errorf("unexpected count %d, min %s and max %s", a.Count(), a.Min(), a.Max())
+	}
+
+	if NewHistogram().Percentile(50) != 0 {
+		t.Error("expected 0 for empty histogram")
+	}
+}
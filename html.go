@@ -0,0 +1,296 @@
+package irest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// DefaultRedactedHeaders are the headers whose values are hidden in reports
+// and traffic logs unless Report.RedactHeaders is set.
+var DefaultRedactedHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Api-Key",
+	"X-Signature",
+	"X-Amz-Security-Token",
+}
+
+const redacted = "[REDACTED]"
+
+type htmlHeader struct {
+	Name  string
+	Value string
+}
+
+type htmlPhase struct {
+	Name  string
+	Width float64
+}
+
+// htmlTest is the view of a single test in the HTML report.
+type htmlTest struct {
+	Name         string
+	Method       string
+	Endpoint     string
+	Status       int
+	State        string
+	Duration     string
+	Errors       []string
+	LatencyError string
+	Requested    bool
+
+	RequestHeaders  []htmlHeader
+	RequestBody     string
+	ResponseHeaders []htmlHeader
+	ResponseBody    string
+
+	// Offset and Width place the request on the timing waterfall as
+	// percentages of the whole run.
+	Offset float64
+	Width  float64
+	Phases []htmlPhase
+	Timing string
+
+	Children []*htmlTest
+}
+
+type htmlReport struct {
+	Name    string
+	Created string
+	Summary JSONSummary
+	Root    *htmlTest
+}
+
+// htmlView converts results for the template, redacting sensitive headers.
+type htmlView struct {
+	redact     map[string]bool
+	start, end time.Time
+}
+
+func newHTMLView(root *result, redact map[string]bool) *htmlView {
+	v := &htmlView{redact: redact}
+
+	// The waterfall spans from the first request start to the last end.
+	root.walk(func(r *result) {
+		if r.Timing.Start.IsZero() {
+			return
+		}
+		if v.start.IsZero() || r.Timing.Start.Before(v.start) {
+			v.start = r.Timing.Start
+		}
+		if end := r.Timing.Start.Add(r.Timing.Total); end.After(v.end) {
+			v.end = end
+		}
+	})
+
+	return v
+}
+
+func (v *htmlView) headers(h http.Header) []htmlHeader {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	headers := []htmlHeader{}
+	for _, name := range names {
+		for _, value := range h[name] {
+			if v.redact[http.CanonicalHeaderKey(name)] {
+				value = redacted
+			}
+			headers = append(headers, htmlHeader{Name: name, Value: value})
+		}
+	}
+	return headers
+}
+
+func (v *htmlView) percent(d time.Duration) float64 {
+	span := v.end.Sub(v.start)
+	if span <= 0 {
+		return 0
+	}
+	return float64(d) / float64(span) * 100
+}
+
+func (v *htmlView) test(r *result) *htmlTest {
+	ht := &htmlTest{
+		Name:      r.Name,
+		Method:    r.Method,
+		Endpoint:  r.Endpoint,
+		Status:    r.Status,
+		State:     "group",
+		Duration:  r.Duration.String(),
+		Errors:    r.failures(),
+		Requested: r.requested(),
+	}
+
+	switch {
+	case r.Skipped:
+		ht.State = "skip"
+	case r.failed():
+		ht.State = "fail"
+	case r.slow():
+		ht.State = "slow"
+	case ht.Requested:
+		ht.State = "pass"
+	}
+	if r.LatencyError != nil {
+		ht.LatencyError = r.LatencyError.Error()
+	}
+
+	if r.Request != nil {
+		ht.RequestHeaders = v.headers(r.Request.Header)
+		ht.RequestBody = prettyBody(r.RequestBody)
+	}
+
+	if r.Response != nil {
+		ht.ResponseHeaders = v.headers(r.Response.Header)
+		ht.ResponseBody = prettyBody(r.ResponseBody)
+	}
+
+	if !r.Timing.Start.IsZero() {
+		t := r.Timing
+		ht.Offset = v.percent(t.Start.Sub(v.start))
+		ht.Width = v.percent(t.Total)
+		ht.Timing = t.String()
+
+		// Phases are sized relative to the request itself.
+		phases := []struct {
+			name string
+			d    time.Duration
+		}{
+			{"dns", t.DNS},
+			{"connect", t.Connect},
+			{"tls", t.TLSHandshake},
+			{"ttfb", t.TimeToFirstByte},
+			{"download", t.Download},
+		}
+		for _, p := range phases {
+			if p.d > 0 && t.Total > 0 {
+				ht.Phases = append(ht.Phases, htmlPhase{p.name, float64(p.d) / float64(t.Total) * 100})
+			}
+		}
+	}
+
+	for _, c := range r.Children {
+		ht.Children = append(ht.Children, v.test(c))
+	}
+
+	return ht
+}
+
+// prettyBody indents JSON bodies and returns any other body as is.
+func prettyBody(body []byte) string {
+	var b bytes.Buffer
+	if err := json.Indent(&b, body, "", "  "); err == nil {
+		return b.String()
+	}
+	return string(body)
+}
+
+// WriteHTML writes the test tree as a single self-contained HTML page with a
+// collapsible test tree, pass and fail filters, the request and response of
+// each test and a timing waterfall. Headers listed in RedactHeaders, or
+// DefaultRedactedHeaders when unset, have their values hidden.
+func (r *Report) WriteHTML(w io.Writer) error {
+	jr, err := r.NewJSONReport()
+	if err != nil {
+		return err
+	}
+
+	root := newResult(r.Test, "", 0)
+	view := newHTMLView(root, r.redactedHeaders())
+
+	return htmlTemplate.Execute(w, htmlReport{
+		Name:    r.Test.Name,
+		Created: r.Test.Created.Format(time.RFC1123),
+		Summary: jr.Summary,
+		Root:    view.test(root),
+	})
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"pct": func(f float64) template.CSS {
+		return template.CSS(fmt.Sprintf("%.2f%%", f))
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Name}} - iREST report</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #222; }
+h1 { font-size: 1.4em; margin-bottom: 0.2em; }
+.meta { color: #666; margin-bottom: 1em; }
+.filters label { margin-right: 1em; }
+details { margin-left: 1.2em; border-left: 1px solid #ddd; padding-left: 0.6em; }
+summary { cursor: pointer; padding: 2px 0; white-space: nowrap; }
+.label { display: inline-block; width: 3.2em; text-align: center; border-radius: 3px; color: #fff; font-size: 0.8em; }
+.pass > summary .label { background: #2e7d32; }
+.fail > summary .label { background: #c62828; }
+.slow > summary .label { background: #f9a825; }
+.group > summary .label { background: #78909c; }
+.skip > summary .label { background: #bdbdbd; }
+.method { font-weight: bold; }
+.duration { color: #666; }
+.error { color: #c62828; }
+.latency { color: #b26a00; }
+.waterfall { position: relative; display: inline-block; width: 240px; height: 10px; background: #f2f2f2; vertical-align: middle; margin: 0 0.6em; }
+.bar { position: absolute; top: 0; height: 10px; display: flex; min-width: 1px; background: #90a4ae; }
+.phase { height: 10px; }
+.phase.dns { background: #26a69a; } .phase.connect { background: #ffa726; } .phase.tls { background: #ab47bc; }
+.phase.ttfb { background: #42a5f5; } .phase.download { background: #66bb6a; }
+.exchange { display: flex; gap: 1em; margin: 0.4em 0 0.8em 1.2em; }
+.exchange > div { flex: 1; min-width: 0; }
+pre { background: #f7f7f7; padding: 0.6em; overflow: auto; max-height: 24em; margin: 0.2em 0; }
+table.headers { font-family: monospace; font-size: 0.85em; border-collapse: collapse; }
+table.headers td { padding: 1px 6px; vertical-align: top; }
+body.hide-pass .pass, body.hide-fail .fail, body.hide-slow .slow, body.hide-skip .skip { display: none; }
+</style>
+</head>
+<body>
+<h1>{{.Name}}</h1>
+<div class="meta">{{.Created}} &middot; {{.Summary.Tests}} tests &middot; {{.Summary.Passed}} passed &middot; {{.Summary.Failed}} failed &middot; {{.Summary.Skipped}} skipped &middot; {{.Summary.Slow}} slow &middot; {{.Summary.Duration}}</div>
+<div class="filters">
+<label><input type="checkbox" data-state="pass" checked> passed</label>
+<label><input type="checkbox" data-state="fail" checked> failed</label>
+<label><input type="checkbox" data-state="slow" checked> slow</label>
+<label><input type="checkbox" data-state="skip" checked> skipped</label>
+</div>
+{{template "test" .Root}}
+<script>
+document.querySelectorAll(".filters input").forEach(function (input) {
+  input.addEventListener("change", function () {
+    document.body.classList.toggle("hide-" + input.dataset.state, !input.checked);
+  });
+});
+</script>
+</body>
+</html>
+{{define "test"}}<details class="{{.State}}"{{if or (eq .State "fail") (eq .State "group")}} open{{end}}>
+<summary><span class="label">{{.State}}</span>
+{{if .Requested}}<span class="waterfall" title="{{.Timing}}"><span class="bar" style="left: {{pct .Offset}}; width: {{pct .Width}}">{{range .Phases}}<span class="phase {{.Name}}" style="flex-basis: {{pct .Width}}"></span>{{end}}</span></span>
+<span class="method">{{.Method}}</span> {{.Endpoint}} [{{.Status}}] <span class="duration">{{.Duration}}</span>{{end}}
+{{.Name}}</summary>
+{{range .Errors}}<div class="error">{{.}}</div>{{end}}
+{{with .LatencyError}}<div class="latency">{{.}}</div>{{end}}
+{{if .Requested}}<div class="exchange">
+<div><strong>Request</strong>
+<table class="headers">{{range .RequestHeaders}}<tr><td>{{.Name}}</td><td>{{.Value}}</td></tr>{{end}}</table>
+{{with .RequestBody}}<pre>{{.}}</pre>{{end}}</div>
+<div><strong>Response</strong>
+<table class="headers">{{range .ResponseHeaders}}<tr><td>{{.Name}}</td><td>{{.Value}}</td></tr>{{end}}</table>
+{{with .ResponseBody}}<pre>{{.}}</pre>{{end}}</div>
+</div>{{end}}
+{{range .Children}}{{template "test" .}}{{end}}
+</details>
+{{end}}`))
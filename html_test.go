@@ -0,0 +1,78 @@
+package irest
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestWriteHTML(t *testing.T) {
+	test := NewTest("unit-test").
+		AddHeader("Authorization", "Bearer secret-token").
+		AddHeader("X-Trace", "trace-value")
+
+	test.NewTest("create").
+		Post(api.URL, "/tests", SampleObject{Name: "unit-test", Value: 100}).
+		MustStatus(http.StatusCreated)
+	test.NewTest("failed get").
+		Get(api.URL, "/tests").
+		MustStatus(http.StatusNotFound)
+
+	var b bytes.Buffer
+	if err := NewColoredCommandLineReport(test).WriteHTML(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	output := b.String()
+	expected := []string{
+		"<!DOCTYPE html>",
+		"<title>unit-test - iREST report</title>",
+		`<details class="fail" open>`,
+		`<details class="pass">`,
+		`data-state="fail"`,
+		`class="waterfall"`,
+		"expected status code response of 404, actual 200",
+		"trace-value",
+		redacted,
+		"\n  &#34;Name&#34;: &#34;unit-test&#34;,",
+	}
+
+	for _, expect := range expected {
+		if !strings.Contains(output, expect) {
+			t.Error("html report output should contain:", expect)
+		}
+	}
+
+	if strings.Contains(output, "secret-token") {
+		t.Error("expected Authorization header to be redacted")
+	}
+
+	if strings.Contains(output, "unit-test-sample-value") {
+		t.Error("expected Set-Cookie header to be redacted")
+	}
+}
+
+func TestWriteHTMLRedactHeaders(t *testing.T) {
+	test := NewTest("unit-test").
+		AddHeader("Authorization", "Bearer secret-token").
+		AddHeader("X-Trace", "trace-value")
+	test.NewTest("get").Get(api.URL, "/tests")
+
+	report := NewColoredCommandLineReport(test)
+	report.RedactHeaders = []string{"x-trace"}
+
+	var b bytes.Buffer
+	if err := report.WriteHTML(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	output := b.String()
+	if strings.Contains(output, "trace-value") {
+		t.Error("expected X-Trace header to be redacted")
+	}
+
+	if !strings.Contains(output, "secret-token") {
+		t.Error("expected Authorization header to be shown")
+	}
+}
@@ -0,0 +1,311 @@
+package irest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CookieJar is an http.CookieJar that also keeps every cookie with its
+// attributes, so the jar can be inspected and saved to a file.
+type CookieJar struct {
+	mu      sync.Mutex
+	jar     *cookiejar.Jar
+	entries []jarEntry
+}
+
+// jarEntry is a cookie with the URL that set it. Host-only cookies, set
+// without a Domain, are only sent to the host that set them.
+type jarEntry struct {
+	URL      string       `json:"url"`
+	Cookie   *http.Cookie `json:"cookie"`
+	HostOnly bool         `json:"hostOnly,omitempty"`
+}
+
+// NewCookieJar creates an empty cookie jar.
+func NewCookieJar() *CookieJar {
+	jar, _ := cookiejar.New(nil)
+	return &CookieJar{jar: jar}
+}
+
+// SetCookies stores the cookies received from the URL. Cookies the jar
+// rejects, such as ones for another domain, are not kept.
+func (j *CookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.jar.SetCookies(u, cookies)
+	for _, c := range cookies {
+		j.store(u, c)
+	}
+}
+
+// store replaces the entry of the same cookie, or removes it when the
+// cookie is deleted.
+func (j *CookieJar) store(u *url.URL, c *http.Cookie) {
+	hostOnly := c.Domain == ""
+	c = jarCookie(u, c)
+
+	expired := cookieExpired(c, time.Now())
+	if !expired && !j.accepted(c) {
+		return
+	}
+
+	entries := j.entries[:0]
+	for _, e := range j.entries {
+		if e.Cookie.Name != c.Name || e.Cookie.Domain != c.Domain || e.Cookie.Path != c.Path {
+			entries = append(entries, e)
+		}
+	}
+	j.entries = entries
+
+	if !expired {
+		j.entries = append(j.entries, jarEntry{URL: u.String(), Cookie: c, HostOnly: hostOnly})
+	}
+}
+
+// accepted reports whether the jar kept the cookie, by whether it would
+// send it back to its domain and path.
+func (j *CookieJar) accepted(c *http.Cookie) bool {
+	host := c.Domain
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+
+	for _, sent := range j.jar.Cookies(&url.URL{Scheme: "https", Host: host, Path: c.Path}) {
+		if sent.Name == c.Name && sent.Value == c.Value {
+			return true
+		}
+	}
+	return false
+}
+
+// jarCookie copies a cookie with the domain and path defaulted from the URL
+// it was received from, and Max-Age turned into an expiry time so it keeps
+// its meaning once saved.
+func jarCookie(u *url.URL, c *http.Cookie) *http.Cookie {
+	copied := *c
+	if copied.MaxAge > 0 {
+		copied.Expires = time.Now().Add(time.Duration(copied.MaxAge) * time.Second)
+		copied.MaxAge = 0
+	}
+	copied.Domain = strings.TrimPrefix(strings.ToLower(copied.Domain), ".")
+	if copied.Domain == "" {
+		copied.Domain = u.Hostname()
+	}
+	if copied.Path == "" || copied.Path[0] != '/' {
+		copied.Path = "/"
+		if i := strings.LastIndex(u.Path, "/"); i > 0 {
+			copied.Path = u.Path[:i]
+		}
+	}
+	return &copied
+}
+
+func cookieExpired(c *http.Cookie, now time.Time) bool {
+	return c.MaxAge < 0 || (!c.Expires.IsZero() && !c.Expires.After(now))
+}
+
+// Cookies returns the cookies to send to the URL.
+func (j *CookieJar) Cookies(u *url.URL) []*http.Cookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.jar.Cookies(u)
+}
+
+// All returns every cookie in the jar that has not expired, with its
+// attributes, in the order they were set.
+func (j *CookieJar) All() []*http.Cookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := time.Now()
+	cookies := []*http.Cookie{}
+	for _, e := range j.entries {
+		if !cookieExpired(e.Cookie, now) {
+			copied := *e.Cookie
+			cookies = append(cookies, &copied)
+		}
+	}
+	return cookies
+}
+
+// Cookie returns the named cookie the jar would send to the URL, with its
+// attributes, or nil.
+func (j *CookieJar) Cookie(rawURL, name string) (*http.Cookie, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	sent := false
+	for _, c := range j.Cookies(u) {
+		if c.Name == name {
+			sent = true
+		}
+	}
+	if !sent {
+		return nil, nil
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	// The most specific cookie is sent first, so prefer the longest path.
+	var found *http.Cookie
+	host, now := u.Hostname(), time.Now()
+	for _, e := range j.entries {
+		c := e.Cookie
+		if c.Name != name || cookieExpired(c, now) || !strings.HasPrefix(u.Path+"/", strings.TrimSuffix(c.Path, "/")+"/") {
+			continue
+		}
+		if host != c.Domain && (e.HostOnly || !strings.HasSuffix(host, "."+c.Domain)) {
+			continue
+		}
+		if found == nil || len(c.Path) > len(found.Path) {
+			copied := *c
+			found = &copied
+		}
+	}
+
+	return found, nil
+}
+
+// Clear removes all cookies from the jar.
+func (j *CookieJar) Clear() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.jar, _ = cookiejar.New(nil)
+	j.entries = nil
+}
+
+// Save writes the cookies in the jar to a JSON file.
+func (j *CookieJar) Save(path string) error {
+	j.mu.Lock()
+	b, err := json.MarshalIndent(j.entries, "", "  ")
+	j.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, b, 0600)
+}
+
+// LoadCookieJar reads a cookie jar saved with Save. Cookies that have
+// expired since are left out.
+func LoadCookieJar(path string) (*CookieJar, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []jarEntry
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return nil, fmt.Errorf("cookie jar %s: %s", path, err)
+	}
+
+	j := NewCookieJar()
+	for _, e := range entries {
+		u, err := url.Parse(e.URL)
+		if err != nil || e.Cookie == nil {
+			return nil, fmt.Errorf("cookie jar %s has an invalid entry", path)
+		}
+		if e.HostOnly {
+			e.Cookie.Domain = ""
+		}
+		j.SetCookies(u, []*http.Cookie{e.Cookie})
+	}
+
+	return j, nil
+}
+
+// Jar returns the cookie jar of the test's client, or nil when it does not
+// have a CookieJar.
+func (t *Test) Jar() *CookieJar {
+	if t.Client == nil {
+		return nil
+	}
+	j, _ := t.Client.Jar.(*CookieJar)
+	return j
+}
+
+// UseJar makes the test and sub-tests created after it use the cookie jar,
+// such as one loaded from a file, instead of the one they share.
+func (t *Test) UseJar(j *CookieJar) *Test {
+	client := http.Client{}
+	if t.Client != nil {
+		client = *t.Client
+	}
+	client.Jar = j
+	t.Client = &client
+	return t
+}
+
+// IsolatedJar gives the test a new empty cookie jar, shared only with
+// sub-tests created after it, to act as a different user.
+func (t *Test) IsolatedJar() *Test {
+	return t.UseJar(NewCookieJar())
+}
+
+// MustHaveCookie sets the Test.Error if the test's cookie jar would not send
+// the named cookie to the URL or a check fails on it.
+func (t *Test) MustHaveCookie(rawURL, name string, checks ...CookieCheck) *Test {
+	if t.Error != nil {
+		return t
+	}
+
+	c, err := t.jarCookie(rawURL, name)
+	if err != nil {
+		t.Error = err
+		return t
+	}
+
+	if c == nil {
+		t.Error = fmt.Errorf("expected cookie %s in jar for %s", name, rawURL)
+		return t
+	}
+
+	for _, check := range checks {
+		if err := check(c); err != nil {
+			t.Error = err
+			return t
+		}
+	}
+
+	return t
+}
+
+// MustNotHaveCookie sets the Test.Error if the test's cookie jar would send
+// the named cookie to the URL.
+func (t *Test) MustNotHaveCookie(rawURL, name string) *Test {
+	if t.Error != nil {
+		return t
+	}
+
+	c, err := t.jarCookie(rawURL, name)
+	if err != nil {
+		t.Error = err
+		return t
+	}
+
+	if c != nil {
+		t.Error = fmt.Errorf("expected no cookie %s in jar for %s, actual %s", name, rawURL, c.Value)
+	}
+
+	return t
+}
+
+func (t *Test) jarCookie(rawURL, name string) (*http.Cookie, error) {
+	j := t.Jar()
+	if j == nil {
+		return nil, fmt.Errorf("test client has no cookie jar")
+	}
+	return j.Cookie(rawURL, name)
+}
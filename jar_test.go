@@ -0,0 +1,172 @@
+package irest
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"testing"
+)
+
+// serveSession logs users in with a session cookie.
+func serveSession(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/session/login":
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: r.URL.Query().Get("user"), Path: "/", HttpOnly: true, MaxAge: 3600})
+	case "/session/logout":
+		http.SetCookie(w, &http.Cookie{Name: "session", Path: "/", MaxAge: -1})
+	default:
+		c, err := r.Cookie("session")
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintf(w, `{"user":%q}`, c.Value)
+	}
+}
+
+type me struct {
+	User string `json:"user"`
+}
+
+func TestSharedJar(t *testing.T) {
+	test := NewTest("session")
+	test.NewTest("login").Get(api.URL, "/session/login?user=alice").MustStatus(http.StatusOK)
+
+	var actual me
+	test.NewTest("me").Get(api.URL, "/session/me").MustStatus(http.StatusOK).ParseResponseBody(&actual)
+	if actual.User != "alice" {
+		t.Errorf("expected the session cookie to be shared with sibling tests, got %+v", actual)
+	}
+
+	test.MustHaveCookie(api.URL+"/session/me", "session", CookieValue(Equals("alice")), CookieHTTPOnly())
+	if test.Error != nil {
+		t.Fatal(test.Error)
+	}
+
+	test.NewTest("logout").Get(api.URL, "/session/logout").MustStatus(http.StatusOK)
+	test.MustNotHaveCookie(api.URL, "session")
+	if test.Error != nil {
+		t.Fatal(test.Error)
+	}
+
+	if cookies := test.Jar().All(); len(cookies) != 0 {
+		t.Errorf("expected the deleted cookie to be removed, got %v", cookies)
+	}
+}
+
+func TestIsolatedJar(t *testing.T) {
+	test := NewTest("users")
+	alice := test.NewTest("alice").IsolatedJar()
+	bob := test.NewTest("bob").IsolatedJar()
+
+	alice.NewTest("login").Get(api.URL, "/session/login?user=alice")
+	bob.NewTest("login").Get(api.URL, "/session/login?user=bob")
+
+	var asAlice, asBob me
+	alice.NewTest("me").Get(api.URL, "/session/me").ParseResponseBody(&asAlice)
+	bob.NewTest("me").Get(api.URL, "/session/me").ParseResponseBody(&asBob)
+
+	if asAlice.User != "alice" || asBob.User != "bob" {
+		t.Errorf("expected separate sessions, got %+v and %+v", asAlice, asBob)
+	}
+
+	test.NewTest("anonymous").Get(api.URL, "/session/me").MustStatus(http.StatusUnauthorized)
+	test.MustNotHaveCookie(api.URL, "session")
+	for _, c := range test.Tests {
+		if c.Error != nil {
+			t.Errorf("%s: %s", c.Name, c.Error)
+		}
+	}
+}
+
+func TestJarEndpointTests(t *testing.T) {
+	test := NewTest("endpoints")
+	login := &Endpoint{Path: "/session/login?user=carol", Method: http.MethodGet}
+	get := &Endpoint{Path: "/session/me", Method: http.MethodGet}
+
+	var actual me
+	test.NewEndpointsTest("session",
+		login.Use(api.URL, nil).In(test).Do().MustStatus(http.StatusOK),
+		get.Use(api.URL, nil).In(test).Do().MustStatus(http.StatusOK).ParseResponseBody(&actual),
+	)
+
+	if actual.User != "carol" {
+		t.Errorf("expected endpoint tests to share the test's jar, got %+v", actual)
+	}
+}
+
+func TestSaveCookieJar(t *testing.T) {
+	test := NewTest("save")
+	test.Get(api.URL, "/session/login?user=dave")
+
+	path := filepath.Join(t.TempDir(), "cookies.json")
+	if err := test.Jar().Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	jar, err := LoadCookieJar(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var actual me
+	loaded := NewTest("loaded").UseJar(jar)
+	loaded.Get(api.URL, "/session/me").MustStatus(http.StatusOK).ParseResponseBody(&actual)
+	if loaded.Error != nil || actual.User != "dave" {
+		t.Errorf("expected the saved session to be used, got %+v, %v", actual, loaded.Error)
+	}
+
+	c, _ := jar.Cookie(api.URL, "session")
+	if c == nil || c.Expires.IsZero() || !c.HttpOnly {
+		t.Errorf("expected saved cookie attributes to be kept, got %+v", c)
+	}
+}
+
+func TestMustHaveCookieErrors(t *testing.T) {
+	test := NewTest("empty").MustHaveCookie("http://localhost", "session")
+	if test.Error == nil {
+		t.Error("expected an error for a missing cookie")
+	}
+
+	test = NewTest("no jar")
+	test.Client = &http.Client{}
+	test.MustHaveCookie("http://localhost", "session")
+	if test.Error == nil {
+		t.Error("expected an error without a cookie jar")
+	}
+}
+
+func TestCookieJarDomains(t *testing.T) {
+	jar := NewCookieJar()
+	u, _ := url.Parse("http://example.com/login")
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "foreign", Value: "0", Domain: "other.com"},
+		{Name: "host", Value: "1"},
+		{Name: "domain", Value: "2", Domain: "example.com"},
+	})
+
+	if cookies := jar.All(); len(cookies) != 2 || cookies[0].Name != "host" || cookies[1].Name != "domain" {
+		t.Errorf("expected only the cookies the jar accepted, got %v", cookies)
+	}
+
+	path := filepath.Join(t.TempDir(), "cookies.json")
+	if err := jar.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadCookieJar(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sub, _ := url.Parse("http://sub.example.com/")
+	if cookies := loaded.Cookies(sub); len(cookies) != 1 || cookies[0].Name != "domain" {
+		t.Errorf("expected only the domain cookie sent to a subdomain, got %v", cookies)
+	}
+	if c, _ := loaded.Cookie(sub.String(), "host"); c != nil {
+		t.Errorf("expected the host-only cookie kept to its host, got %+v", c)
+	}
+	if c, _ := loaded.Cookie("http://example.com/", "host"); c == nil || c.Value != "1" {
+		t.Errorf("expected the host-only cookie for its host, got %+v", c)
+	}
+}
@@ -0,0 +1,130 @@
+package irest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// JSONReportVersion is the version of the JSON report schema written by
+// WriteJSON. It is increased whenever a change is not backwards compatible.
+const JSONReportVersion = 1
+
+// JSONReport is the machine-readable form of a test run. Durations are in
+// nanoseconds.
+type JSONReport struct {
+	Version int         `json:"version"`
+	Name    string      `json:"name"`
+	Created time.Time   `json:"created"`
+	Summary JSONSummary `json:"summary"`
+	Test    *JSONResult `json:"test"`
+
+	// Endpoints are the latency statistics of each endpoint requested.
+	Endpoints []EndpointStats `json:"endpoints,omitempty"`
+}
+
+// JSONSummary counts the tests that made requests in a run.
+type JSONSummary struct {
+	Tests    int           `json:"tests"`
+	Passed   int           `json:"passed"`
+	Failed   int           `json:"failed"`
+	Skipped  int           `json:"skipped"`
+	Slow     int           `json:"slow"`
+	Duration time.Duration `json:"duration"`
+}
+
+// JSONResult is a single test, or endpoint test, and its nested tests.
+type JSONResult struct {
+	Name         string        `json:"name"`
+	Path         string        `json:"path"`
+	Method       string        `json:"method,omitempty"`
+	Endpoint     string        `json:"endpoint,omitempty"`
+	Status       int           `json:"status,omitempty"`
+	Duration     time.Duration `json:"duration"`
+	Timing       *Timing       `json:"timing,omitempty"`
+	Compression  *Compression  `json:"compression,omitempty"`
+	Errors       []string      `json:"errors,omitempty"`
+	LatencyError string        `json:"latencyError,omitempty"`
+	Skipped      bool          `json:"skipped,omitempty"`
+	Tests        []*JSONResult `json:"tests,omitempty"`
+}
+
+// Failed reports whether the test itself failed.
+func (r *JSONResult) Failed() bool {
+	return !r.Skipped && len(r.Errors) > 0
+}
+
+func newJSONResult(r *result) *JSONResult {
+	jr := &JSONResult{
+		Name:        r.Name,
+		Path:        r.Path,
+		Method:      r.Method,
+		Endpoint:    r.Endpoint,
+		Status:      r.Status,
+		Duration:    r.Duration,
+		Compression: r.Compression,
+		Errors:      r.failures(),
+		Skipped:     r.Skipped,
+	}
+
+	if r.Response != nil {
+		timing := r.Timing
+		jr.Timing = &timing
+	}
+
+	if r.LatencyError != nil {
+		jr.LatencyError = r.LatencyError.Error()
+	}
+
+	for _, c := range r.Children {
+		jr.Tests = append(jr.Tests, newJSONResult(c))
+	}
+
+	return jr
+}
+
+// NewJSONReport builds the JSON report of the report's test tree.
+func (r *Report) NewJSONReport() (*JSONReport, error) {
+	if r.Test == nil {
+		return nil, fmt.Errorf("Report.Test must be set")
+	}
+
+	root := newResult(r.Test, "", 0)
+
+	return &JSONReport{
+		Version:   JSONReportVersion,
+		Name:      r.Test.Name,
+		Created:   r.Test.Created,
+		Summary:   root.summary(),
+		Test:      newJSONResult(root),
+		Endpoints: endpointStats(root, r.Test.treeBudgets()),
+	}, nil
+}
+
+// WriteJSON writes the test tree as an indented JSON report.
+func (r *Report) WriteJSON(w io.Writer) error {
+	jr, err := r.NewJSONReport()
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(jr)
+}
+
+// ReadJSONReport loads a report previously written by WriteJSON. Reports from
+// a newer, unknown schema version are rejected.
+func ReadJSONReport(r io.Reader) (*JSONReport, error) {
+	jr := &JSONReport{}
+	if err := json.NewDecoder(r).Decode(jr); err != nil {
+		return nil, err
+	}
+
+	if jr.Version < 1 || jr.Version > JSONReportVersion {
+		return nil, fmt.Errorf("unsupported json report version %d", jr.Version)
+	}
+
+	return jr, nil
+}
@@ -0,0 +1,80 @@
+package irest
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteJSONRoundTrip(t *testing.T) {
+	test := NewTest("unit-test")
+
+	test.NewTest("create").
+		Post(api.URL, "/tests", nil).
+		MustStatus(http.StatusCreated)
+	test.NewTest("failed get").
+		Get(api.URL, "/tests").
+		MustStatus(http.StatusNotFound)
+	test.NewTest("slow get").
+		Get(api.URL, "/tests").
+		MustRespondWithin(time.Nanosecond)
+
+	var b bytes.Buffer
+	if err := NewColoredCommandLineReport(test).WriteJSON(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(b.String(), "{}") {
+		t.Error("expected errors to be written as strings:", b.String())
+	}
+
+	jr, err := ReadJSONReport(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if jr.Version != JSONReportVersion || jr.Name != "unit-test" {
+		t.Errorf("unexpected report version %d and name %s", jr.Version, jr.Name)
+	}
+
+	summary := JSONSummary{Tests: 3, Passed: 1, Failed: 1, Slow: 1}
+	summary.Duration = jr.Summary.Duration
+	if jr.Summary != summary {
+		t.Errorf("expected summary %+v, got %+v", summary, jr.Summary)
+	}
+
+	if len(jr.Test.Tests) != 3 {
+		t.Fatalf("expected 3 nested tests, got %d", len(jr.Test.Tests))
+	}
+
+	failed := jr.Test.Tests[1]
+	if failed.Path != "unit-test/failed get" || !failed.Failed() {
+		t.Errorf("expected failed test at unit-test/failed get, got %+v", failed)
+	}
+
+	if failed.Method != http.MethodGet || failed.Endpoint != "/tests" || failed.Status != http.StatusOK {
+		t.Errorf("unexpected request details %s %s %d", failed.Method, failed.Endpoint, failed.Status)
+	}
+
+	if failed.Timing == nil || failed.Timing.Total != failed.Duration {
+		t.Errorf("expected timing total to match duration, got %+v", failed.Timing)
+	}
+
+	if jr.Test.Tests[2].LatencyError == "" {
+		t.Error("expected latency error to be set")
+	}
+}
+
+func TestReadJSONReportVersion(t *testing.T) {
+	_, err := ReadJSONReport(strings.NewReader(`{"version": 99}`))
+	if err == nil {
+		t.Error("expected an error, but did not get one")
+	}
+
+	_, err = ReadJSONReport(strings.NewReader(`not json`))
+	if err == nil {
+		t.Error("expected an error, but did not get one")
+	}
+}
@@ -0,0 +1,185 @@
+package irest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// junitTestSuites is the root element of a JUnit XML report.
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr,omitempty"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name       string          `xml:"name,attr"`
+	ClassName  string          `xml:"classname,attr"`
+	Time       string          `xml:"time,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Failures   []junitFailure  `xml:"failure,omitempty"`
+	Skipped    *junitSkipped   `xml:"skipped,omitempty"`
+	SystemOut  string          `xml:"system-out,omitempty"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitSkipped struct{}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// junitSeconds formats a duration as the seconds JUnit expects.
+func junitSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
+
+// WriteJUnit writes the test tree as JUnit XML. Every test that groups other
+// tests becomes a testsuite, named by its path in the tree, holding a
+// testcase for each test that made a request. Failed testcases include the
+// request and response in system-out, with the values of RedactHeaders, or
+// DefaultRedactedHeaders when unset, hidden.
+func (r *Report) WriteJUnit(w io.Writer) error {
+	if r.Test == nil {
+		return fmt.Errorf("Report.Test must be set")
+	}
+
+	root := newResult(r.Test, "", 0)
+	doc := junitTestSuites{Name: root.Name}
+	redact := r.redactedHeaders()
+
+	var total time.Duration
+	root.walk(func(group *result) {
+		suite := junitTestSuite{Name: group.Path}
+		if !group.Created.IsZero() {
+			suite.Timestamp = group.Created.Format("2006-01-02T15:04:05")
+		}
+
+		// A group that made a request itself is its own first testcase.
+		cases := []*result{}
+		if len(group.Children) > 0 && group.requested() {
+			cases = append(cases, group)
+		}
+		for _, c := range group.Children {
+			if len(c.Children) == 0 {
+				cases = append(cases, c)
+			}
+		}
+
+		// Only the root may make a request without being in a group.
+		if group == root && len(root.Children) == 0 && root.requested() {
+			cases = append(cases, root)
+		}
+
+		if len(cases) == 0 {
+			return
+		}
+
+		var suiteTime time.Duration
+		for _, c := range cases {
+			tc := newJUnitTestCase(c, group.Path, redact)
+			if len(tc.Failures) > 0 {
+				suite.Failures++
+			}
+			if tc.Skipped != nil {
+				suite.Skipped++
+			}
+			suite.Cases = append(suite.Cases, tc)
+			suiteTime += c.Duration
+		}
+
+		suite.Tests = len(suite.Cases)
+		suite.Time = junitSeconds(suiteTime)
+
+		doc.Tests += suite.Tests
+		doc.Failures += suite.Failures
+		doc.Skipped += suite.Skipped
+		doc.Suites = append(doc.Suites, suite)
+		total += suiteTime
+	})
+	doc.Time = junitSeconds(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func newJUnitTestCase(r *result, className string, redact map[string]bool) junitTestCase {
+	tc := junitTestCase{
+		Name:      r.Name,
+		ClassName: strings.Replace(className, "/", ".", -1),
+		Time:      junitSeconds(r.Duration),
+		Properties: []junitProperty{
+			{Name: "method", Value: r.Method},
+			{Name: "endpoint", Value: r.Endpoint},
+			{Name: "status", Value: strconv.Itoa(r.Status)},
+		},
+	}
+
+	for _, msg := range r.failures() {
+		tc.Failures = append(tc.Failures, junitFailure{
+			Message: msg,
+			Type:    "failure",
+			Text:    fmt.Sprintf("%s for %s %s", msg, r.Method, r.Endpoint),
+		})
+	}
+
+	if r.Skipped {
+		tc.Skipped = &junitSkipped{}
+		return tc
+	}
+
+	if r.LatencyError != nil {
+		tc.Failures = append(tc.Failures, junitFailure{
+			Message: r.LatencyError.Error(),
+			Type:    "latency",
+			Text:    r.Timing.String(),
+		})
+	}
+
+	if len(tc.Failures) > 0 {
+		var out []string
+		if req := r.dumpRequest(redact); req != "" {
+			out = append(out, req)
+		}
+		if res := r.dumpResponse(redact); res != "" {
+			out = append(out, res)
+		}
+		tc.SystemOut = strings.Join(out, "\n\n")
+	}
+
+	return tc
+}
@@ -0,0 +1,132 @@
+package irest
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestWriteJUnit(t *testing.T) {
+	test := NewTest("unit-test")
+
+	test.NewTest("create").
+		Post(api.URL, "/tests", SampleObject{Name: "unit-test"}).
+		MustStatus(http.StatusCreated)
+
+	group := test.NewTest("group")
+	group.NewTest("get").
+		Get(api.URL, "/tests").
+		MustStatus(http.StatusOK)
+	group.NewTest("failed get").
+		Get(api.URL, "/tests").
+		MustStatus(http.StatusNotFound)
+
+	getEndpoint := &Endpoint{Path: "/tests", Method: http.MethodGet}
+	test.NewEndpointsTest("scenario",
+		getEndpoint.Use(api.URL, nil).Do().MustStatus(http.StatusOK),
+	)
+
+	var b bytes.Buffer
+	if err := NewColoredCommandLineReport(test).WriteJUnit(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	output := b.String()
+	if !strings.HasPrefix(output, xml.Header) {
+		t.Error("expected xml header:", output)
+	}
+
+	doc := junitTestSuites{}
+	if err := xml.Unmarshal(b.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.Tests != 4 || doc.Failures != 1 {
+		t.Errorf("expected 4 tests and 1 failure, got %d and %d", doc.Tests, doc.Failures)
+	}
+
+	if len(doc.Suites) != 3 {
+		t.Fatalf("expected 3 test suites, got %d", len(doc.Suites))
+	}
+
+	names := []string{"unit-test", "unit-test/group", "unit-test/scenario"}
+	for i, name := range names {
+		if doc.Suites[i].Name != name {
+			t.Errorf("expected suite %s, got %s", name, doc.Suites[i].Name)
+		}
+	}
+
+	failed := doc.Suites[1].Cases[1]
+	if failed.Name != "failed get" || len(failed.Failures) != 1 {
+		t.Fatalf("expected failed get to have a failure, got %+v", failed)
+	}
+
+	if !strings.Contains(failed.Failures[0].Message, "expected status code response of 404") {
+		t.Errorf("unexpected failure message %s", failed.Failures[0].Message)
+	}
+
+	if !strings.Contains(failed.SystemOut, "GET /tests HTTP/1.1") || !strings.Contains(failed.SystemOut, "HTTP/1.1 200 OK") {
+		t.Error("expected request and response dump in system-out:", failed.SystemOut)
+	}
+
+	passed := doc.Suites[1].Cases[0]
+	if passed.SystemOut != "" {
+		t.Error("expected no system-out for passing testcase")
+	}
+
+	expected := []junitProperty{
+		{Name: "method", Value: "GET"},
+		{Name: "endpoint", Value: "/tests"},
+		{Name: "status", Value: "200"},
+	}
+	for i, p := range expected {
+		if passed.Properties[i] != p {
+			t.Errorf("expected property %+v, got %+v", p, passed.Properties[i])
+		}
+	}
+
+	step := doc.Suites[2].Cases[0]
+	if step.Name != "GET /tests" || step.ClassName != "unit-test.scenario" {
+		t.Errorf("unexpected endpoint testcase %s in %s", step.Name, step.ClassName)
+	}
+}
+
+func TestWriteJUnitNoTest(t *testing.T) {
+	report := &Report{}
+
+	if err := report.WriteJUnit(&bytes.Buffer{}); err == nil {
+		t.Error("expected an error, but did not get one")
+	}
+}
+
+func TestWriteJUnitRedactsHeaders(t *testing.T) {
+	test := NewTest("unit-test")
+	test.NewTest("secret").
+		AddHeader("Authorization", "Bearer secret-token").
+		AddHeader("X-Trace", "trace-1").
+		Get(api.URL, "/tests").
+		MustStatus(http.StatusNotFound)
+
+	var b bytes.Buffer
+	if err := NewColoredCommandLineReport(test).WriteJUnit(&b); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(b.String(), "secret-token") || !strings.Contains(b.String(), "Authorization: "+redacted) {
+		t.Error("expected the Authorization header redacted:", b.String())
+	}
+	if !strings.Contains(b.String(), "X-Trace: trace-1") {
+		t.Error("expected other headers kept:", b.String())
+	}
+
+	b.Reset()
+	report := NewColoredCommandLineReport(test)
+	report.RedactHeaders = []string{"x-trace"}
+	if err := report.WriteJUnit(&b); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(b.String(), "trace-1") || !strings.Contains(b.String(), "secret-token") {
+		t.Error("expected only RedactHeaders redacted:", b.String())
+	}
+}
@@ -0,0 +1,208 @@
+package irest
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// latencyBudgets maps endpoint templates, such as "/examples/%d", to the
+// maximum time a response may take. It is shared by a test and its sub-tests.
+type latencyBudgets map[string]latencyBudget
+
+// latencyBudget is the maximum response time for an endpoint template,
+// compiled when the budget is set.
+type latencyBudget struct {
+	endpointTemplate
+	max time.Duration
+}
+
+// templateVerb matches the fmt verbs used as variables in endpoint paths.
+var templateVerb = regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z]`)
+
+// endpointTemplate is an endpoint template compiled to match the paths built
+// from it.
+type endpointTemplate struct {
+	template string
+	pattern  *regexp.Regexp
+
+	// variables and literal are the number of fmt verbs and of other
+	// characters in the template, for choosing the most specific match.
+	variables, literal int
+}
+
+// newEndpointTemplate compiles an endpoint template into a regular expression
+// that matches the paths built from it, treating each fmt verb as a path
+// segment.
+func newEndpointTemplate(template string) endpointTemplate {
+	t := endpointTemplate{template: template}
+	trimmed := strings.TrimPrefix(template, "/")
+
+	var b strings.Builder
+	b.WriteString("^/?")
+	last := 0
+	for _, loc := range templateVerb.FindAllStringIndex(trimmed, -1) {
+		b.WriteString(regexp.QuoteMeta(trimmed[last:loc[0]]))
+		b.WriteString("[^/?]+")
+		t.literal += loc[0] - last
+		t.variables++
+		last = loc[1]
+	}
+	b.WriteString(regexp.QuoteMeta(trimmed[last:]))
+	b.WriteString(`(\?.*)?$`)
+	t.literal += len(trimmed) - last
+
+	t.pattern = regexp.MustCompile(b.String())
+	return t
+}
+
+// match reports whether path could have been built from the template.
+func (t endpointTemplate) match(path string) bool {
+	return t.template == path || t.pattern.MatchString(path)
+}
+
+// moreSpecific reports whether the template matches fewer paths than other,
+// having fewer variables or, with as many, more literal characters. Ties go
+// to the template sorting first, so the choice does not depend on map order.
+func (t endpointTemplate) moreSpecific(other endpointTemplate) bool {
+	if t.variables != other.variables {
+		return t.variables < other.variables
+	}
+	if t.literal != other.literal {
+		return t.literal > other.literal
+	}
+	return t.template < other.template
+}
+
+// mostSpecific returns the most specific of the templates matching path.
+func mostSpecific(templates []endpointTemplate, path string) (endpointTemplate, bool) {
+	var best endpointTemplate
+	found := false
+	for _, t := range templates {
+		if t.match(path) && (!found || t.moreSpecific(best)) {
+			best, found = t, true
+		}
+	}
+	return best, found
+}
+
+// matchTemplate reports whether path could have been built from template.
+func matchTemplate(template, path string) bool {
+	return newEndpointTemplate(template).match(path)
+}
+
+// templates returns the compiled templates of the budgets.
+func (b latencyBudgets) templates() []endpointTemplate {
+	templates := make([]endpointTemplate, 0, len(b))
+	for _, budget := range b {
+		templates = append(templates, budget.endpointTemplate)
+	}
+	return templates
+}
+
+// lookup finds the budget for a path or template, preferring an exact match
+// over the most specific template matching it.
+func (b latencyBudgets) lookup(path string) (time.Duration, bool) {
+	if budget, ok := b[path]; ok {
+		return budget.max, true
+	}
+
+	if t, ok := mostSpecific(b.templates(), path); ok {
+		return b[t.template].max, true
+	}
+
+	return 0, false
+}
+
+func checkLatency(actual, budget time.Duration) error {
+	if actual > budget {
+		return fmt.Errorf("expected response within %s, actual %s", budget, actual)
+	}
+	return nil
+}
+
+// SetLatencyBudget sets the default maximum response time for requests to an
+// endpoint template, e.g. "/examples/%d", for this test and the sub-tests
+// created after it. Requests over budget set LatencyError instead of Error.
+func (t *Test) SetLatencyBudget(template string, d time.Duration) *Test {
+	// Copied so the budget does not reach the parent or sibling tests
+	// sharing the map.
+	budgets := latencyBudgets{}
+	for name, budget := range t.budgets {
+		budgets[name] = budget
+	}
+	budgets[template] = latencyBudget{endpointTemplate: newEndpointTemplate(template), max: d}
+	t.budgets = budgets
+	return t
+}
+
+// treeBudgets returns the latency budgets set anywhere in the test tree, for
+// grouping requests by template in reports.
+func (t *Test) treeBudgets() latencyBudgets {
+	budgets := latencyBudgets{}
+	var walk func(*Test)
+	walk = func(t *Test) {
+		for template, budget := range t.budgets {
+			budgets[template] = budget
+		}
+		for _, sub := range t.Tests {
+			walk(sub)
+		}
+	}
+	walk(t)
+	return budgets
+}
+
+// MustRespondWithin sets the Test.LatencyError if the response took longer
+// than the given duration. An HTTP request must have been made prior to this
+// function call.
+func (t *Test) MustRespondWithin(d time.Duration) *Test {
+	if t.Response == nil {
+		return t
+	}
+
+	if err := checkLatency(t.Duration, d); err != nil {
+		t.LatencyError = err
+	}
+
+	return t
+}
+
+func (t *Test) checkLatencyBudget() {
+	if t.Response == nil {
+		return
+	}
+
+	if budget, ok := t.budgets.lookup(t.Endpoint); ok {
+		if err := checkLatency(t.Duration, budget); err != nil {
+			t.LatencyError = err
+		}
+	}
+}
+
+// MustRespondWithin sets the EndpointTest.LatencyError if the response took
+// longer than the given duration.
+func (e *EndpointTest) MustRespondWithin(d time.Duration) *EndpointTest {
+	if e.Response == nil {
+		return e
+	}
+
+	if err := checkLatency(e.Duration, d); err != nil {
+		e.LatencyError = err
+	}
+
+	return e
+}
+
+func (e *EndpointTest) checkLatencyBudget() {
+	if e.Response == nil || e.Parent == nil {
+		return
+	}
+
+	if budget, ok := e.Parent.budgets.lookup(e.Path); ok {
+		if err := checkLatency(e.Duration, budget); err != nil {
+			e.LatencyError = err
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package irest
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// respondSlowly responds after 50ms.
+func respondSlowly(w http.ResponseWriter, r *http.Request) {
+	time.Sleep(time.Millisecond * 50)
+	w.WriteHeader(http.StatusOK)
+}
+
+func TestMatchTemplate(t *testing.T) {
+	var templateTests = []struct {
+		template string
+		path     string
+		match    bool
+	}{
+		{"/examples/%d", "/examples/1", true},
+		{"/examples/%d", "examples/12", true},
+		{"/examples/%d", "/examples/12?full=true", true},
+		{"/examples/%d", "/examples/1/sub", false},
+		{"/examples/%s/sub/%03d", "/examples/abc/sub/007", true},
+		{"/examples", "/examples", true},
+		{"/examples", "/examples/1", false},
+		{"/a.b/%v", "/axb/1", false},
+	}
+
+	for _, tt := range templateTests {
+		if matchTemplate(tt.template, tt.path) != tt.match {
+			t.Errorf("expected match of %s against %s to be %t", tt.template, tt.path, tt.match)
+		}
+	}
+}
+
+func TestLatencyBudgetMostSpecific(t *testing.T) {
+	test := NewTest("unit-test").
+		SetLatencyBudget("/%s/%d", time.Second).
+		SetLatencyBudget("/things/%d", 2*time.Second).
+		SetLatencyBudget("/things/%s", 3*time.Second).
+		SetLatencyBudget("/things/latest", 4*time.Second)
+
+	var lookupTests = []struct {
+		path   string
+		budget time.Duration
+	}{
+		{"/things/1", 2 * time.Second},
+		{"/things/latest?full=true", 4 * time.Second},
+		{"/users/1", time.Second},
+		{"/things/%d", 2 * time.Second},
+	}
+
+	for _, tt := range lookupTests {
+		if budget, ok := test.budgets.lookup(tt.path); !ok || budget != tt.budget {
+			t.Errorf("%s: expected budget %s, actual %s", tt.path, tt.budget, budget)
+		}
+	}
+}
+
+func TestMustRespondWithin(t *testing.T) {
+	test := NewTest("unit-test").
+		Get(api.URL, "/slow").
+		MustStatus(http.StatusOK).
+		MustRespondWithin(time.Millisecond * 10)
+
+	if test.Error != nil {
+		t.Errorf("expected no functional error, got %s", test.Error)
+	}
+
+	if test.LatencyError == nil {
+		t.Error("expected a latency error, but did not get one")
+	}
+
+	test = NewTest("unit-test").
+		Get(api.URL, "/fast").
+		MustRespondWithin(time.Second)
+
+	if test.LatencyError != nil {
+		t.Error(test.LatencyError)
+	}
+}
+
+func TestLatencyBudgetInherited(t *testing.T) {
+	test := NewTest("unit-test").
+		SetLatencyBudget("/slow/%d", time.Millisecond*10)
+
+	slow := test.NewTest("slow").Get(api.URL, "/slow/1")
+	if slow.LatencyError == nil {
+		t.Error("expected a latency error, but did not get one")
+	}
+
+	fast := test.NewTest("fast").Get(api.URL, "/fast/1")
+	if fast.LatencyError != nil {
+		t.Error(fast.LatencyError)
+	}
+}
+
+func TestLatencyBudgetNotShared(t *testing.T) {
+	test := NewTest("unit-test").
+		SetLatencyBudget("/slow/%d", time.Second)
+
+	child := test.NewTest("child").SetLatencyBudget("/slow/%d", time.Millisecond*10)
+	sibling := test.NewTest("sibling")
+
+	if budget, _ := test.budgets.lookup("/slow/1"); budget != time.Second {
+		t.Errorf("expected the parent budget unchanged, got %s", budget)
+	}
+	if budget, _ := sibling.budgets.lookup("/slow/1"); budget != time.Second {
+		t.Errorf("expected the sibling budget unchanged, got %s", budget)
+	}
+	if budget, _ := child.budgets.lookup("/slow/1"); budget != time.Millisecond*10 {
+		t.Errorf("expected the child budget, got %s", budget)
+	}
+
+	if slow := sibling.Get(api.URL, "/slow/1"); slow.LatencyError != nil {
+		t.Error(slow.LatencyError)
+	}
+	if slow := child.Get(api.URL, "/slow/1"); slow.LatencyError == nil {
+		t.Error("expected a latency error, but did not get one")
+	}
+}
+
+func TestEndpointLatencyBudget(t *testing.T) {
+	slowEndpoint := &Endpoint{Path: "/slow/%d", Method: http.MethodGet}
+
+	test := NewTest("unit-test").
+		SetLatencyBudget(slowEndpoint.Path, time.Millisecond*10)
+
+	scenario := test.NewEndpointsTest("scenario",
+		slowEndpoint.Use(api.URL, nil, 1).Do().MustStatus(http.StatusOK),
+	)
+
+	if len(scenario.EndpointTests) != 1 {
+		t.Fatalf("expected 1 endpoint test, got %d", len(scenario.EndpointTests))
+	}
+
+	if scenario.EndpointTests[0].LatencyError == nil {
+		t.Error("expected a latency error, but did not get one")
+	}
+}
+
+func TestReportSlowLabel(t *testing.T) {
+	test := NewTest("unit-test")
+	test.NewTest("slow").
+		Get(api.URL, "/slow").
+		MustRespondWithin(time.Millisecond * 10)
+
+	report := NewColoredCommandLineReport(test)
+	report.SlowTestLabel = "[SLOW]"
+	report.FastThreshold = time.Millisecond * 10
+	report.SlowThreshold = time.Millisecond * 20
+
+	var b bytes.Buffer
+	if err := report.WriteReport(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	output := b.String()
+	if !strings.Contains(output, "[SLOW]") {
+		t.Error("expected slow label in report output:", output)
+	}
+
+	if !strings.Contains(output, "\033[00;31m") {
+		t.Error("expected response to be colored over slow threshold:", output)
+	}
+}
@@ -0,0 +1,491 @@
+package irest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+// Scenario is a sequence of endpoint requests that each iteration of a load
+// test makes in order.
+type Scenario struct {
+	Name    string
+	BaseURL string
+	Steps   []*ScenarioStep
+
+	// Header is sent with every request of the scenario.
+	Header http.Header
+}
+
+// ScenarioStep is a single request of a scenario built from an Endpoint.
+type ScenarioStep struct {
+	// Name defaults to the method and path of the endpoint.
+	Name     string
+	Endpoint *Endpoint
+	Payload  interface{}
+
+	// Args returns the path variables for an iteration, numbered from zero.
+	// Nil uses no variables.
+	Args func(iteration int) []interface{}
+
+	// Status is the expected status code. Zero accepts any status below 400.
+	Status int
+}
+
+func (s *ScenarioStep) name() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return fmt.Sprintf("%s %s", s.Endpoint.Method, s.Endpoint.Path)
+}
+
+// Executor schedules the iterations of a load test.
+type Executor interface {
+	// Execute runs iterations with run.Iterate until the executor is done.
+	Execute(run *LoadRun) error
+
+	// concurrency is the most iterations the executor runs at once, used to
+	// size the connection pool.
+	concurrency() int
+}
+
+// Stage ramps the number of active virtual users linearly from the previous
+// target, or VirtualUsers.VUs for the first stage, to Target over Duration.
+type Stage struct {
+	Duration time.Duration
+	Target   int
+}
+
+// VirtualUsers is a closed model executor: each virtual user runs the next
+// iteration as soon as its previous one is done.
+type VirtualUsers struct {
+	// VUs is the number of virtual users, or where the first stage starts.
+	VUs int
+
+	// Duration is how long to run for. Iterations that have started when it
+	// ends are completed.
+	Duration time.Duration
+
+	// Iterations is the total number of iterations to run across all virtual
+	// users. Zero runs until Duration or the stages end.
+	Iterations int
+
+	// Stages change the number of active virtual users over time and replace
+	// Duration with their total duration.
+	Stages []Stage
+}
+
+func (v VirtualUsers) concurrency() int {
+	n := v.VUs
+	for _, s := range v.Stages {
+		if s.Target > n {
+			n = s.Target
+		}
+	}
+	return n
+}
+
+// duration is the total duration, from the stages if there are any.
+func (v VirtualUsers) duration() time.Duration {
+	if len(v.Stages) == 0 {
+		return v.Duration
+	}
+
+	var d time.Duration
+	for _, s := range v.Stages {
+		d += s.Duration
+	}
+	return d
+}
+
+// target returns how many virtual users are active after elapsed time.
+func (v VirtualUsers) target(elapsed time.Duration) int {
+	if len(v.Stages) == 0 {
+		return v.VUs
+	}
+
+	from := v.VUs
+	for _, s := range v.Stages {
+		if elapsed < s.Duration {
+			progress := float64(elapsed) / float64(s.Duration)
+			return from + int(math.Round(float64(s.Target-from)*progress))
+		}
+		elapsed -= s.Duration
+		from = s.Target
+	}
+	return from
+}
+
+// Execute runs the virtual users until the duration ends or all iterations
+// have been started.
+func (v VirtualUsers) Execute(run *LoadRun) error {
+	duration := v.duration()
+	if v.concurrency() < 1 {
+		return fmt.Errorf("virtual users must be at least 1")
+	}
+	if duration <= 0 && v.Iterations <= 0 {
+		return fmt.Errorf("virtual users need a duration, stages or iterations")
+	}
+
+	start := time.Now()
+	var started int64
+
+	var wg sync.WaitGroup
+	for vu := 0; vu < v.concurrency(); vu++ {
+		wg.Add(1)
+		go func(vu int) {
+			defer wg.Done()
+			for {
+				elapsed := time.Since(start)
+				if duration > 0 && elapsed >= duration {
+					return
+				}
+
+				// Inactive virtual users wait to be ramped up.
+				if vu >= v.target(elapsed) {
+					time.Sleep(10 * time.Millisecond)
+					continue
+				}
+
+				if v.Iterations > 0 && atomic.AddInt64(&started, 1) > int64(v.Iterations) {
+					return
+				}
+
+				run.Iterate(time.Time{})
+			}
+		}(vu)
+	}
+	wg.Wait()
+
+	return nil
+}
+
+// ConstantArrivalRate is an open model executor: it starts iterations at a
+// fixed rate whatever the response times, as real users arriving would. Slow
+// responses therefore show up as higher latencies rather than fewer requests,
+// avoiding coordinated omission. Latencies are measured from when each
+// iteration was scheduled to start.
+type ConstantArrivalRate struct {
+	// Rate is the number of iterations started per second.
+	Rate float64
+
+	// Duration is how long to start iterations for. Iterations in flight
+	// when it ends are completed.
+	Duration time.Duration
+
+	// MaxInFlight bounds how many iterations run at once. Iterations due
+	// when it is reached are dropped and counted. Zero allows 100.
+	MaxInFlight int
+}
+
+func (c ConstantArrivalRate) concurrency() int {
+	if c.MaxInFlight <= 0 {
+		return 100
+	}
+	return c.MaxInFlight
+}
+
+// Execute starts iterations at the rate until the duration ends.
+func (c ConstantArrivalRate) Execute(run *LoadRun) error {
+	if c.Rate <= 0 {
+		return fmt.Errorf("arrival rate must be greater than 0")
+	}
+	if c.Duration <= 0 {
+		return fmt.Errorf("arrival rate needs a duration")
+	}
+
+	interval := time.Duration(float64(time.Second) / c.Rate)
+	inFlight := make(chan struct{}, c.concurrency())
+
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; ; i++ {
+		scheduled := start.Add(time.Duration(i) * interval)
+		if scheduled.Sub(start) >= c.Duration {
+			break
+		}
+
+		// When behind schedule, iterations start immediately to catch up.
+		if wait := time.Until(scheduled); wait > 0 {
+			time.Sleep(wait)
+		}
+
+		select {
+		case inFlight <- struct{}{}:
+			wg.Add(1)
+			go func(scheduled time.Time) {
+				defer wg.Done()
+				run.Iterate(scheduled)
+				<-inFlight
+			}(scheduled)
+		default:
+			run.Dropped()
+		}
+	}
+	wg.Wait()
+
+	return nil
+}
+
+// LoadRun is a load test in progress, given to the Executor to run the
+// iterations of the scenario.
+type LoadRun struct {
+	scenario *Scenario
+	client   *http.Client
+
+	iterations int64
+	dropped    int64
+
+	steps []*StepResult
+}
+
+// Iterate runs one iteration, making each request of the scenario in order.
+// For executors that start iterations on a schedule, scheduled is when the
+// iteration was meant to start and any delay in starting it is added to the
+// latency of the first request. Otherwise it is the zero time.
+func (r *LoadRun) Iterate(scheduled time.Time) {
+	iteration := int(atomic.AddInt64(&r.iterations, 1) - 1)
+
+	var delay time.Duration
+	if !scheduled.IsZero() {
+		delay = time.Since(scheduled)
+	}
+
+	for i, step := range r.scenario.Steps {
+		var args []interface{}
+		if step.Args != nil {
+			args = step.Args(iteration)
+		}
+
+		e := step.Endpoint.Use(r.scenario.BaseURL, step.Payload, args...)
+		e.Client = r.client
+		for name, values := range r.scenario.Header {
+			(*e.Header)[name] = values
+		}
+		begin := time.Now()
+		e.Do()
+
+		var kind string
+		switch {
+		case e.Error != nil:
+			kind = requestErrorKind(e.Error)
+		case step.Status != 0 && e.Response.StatusCode != step.Status:
+			kind = fmt.Sprintf("status %d", e.Response.StatusCode)
+		case step.Status == 0 && e.Response.StatusCode >= 400:
+			kind = fmt.Sprintf("status %d", e.Response.StatusCode)
+		}
+
+		// Failed requests count the time until they failed, keeping timeouts
+		// in the percentiles. Ones failing before being sent have no
+		// Duration.
+		latency := e.Duration
+		if e.Error != nil && latency == 0 {
+			latency = time.Since(begin)
+		}
+		if i == 0 {
+			latency += delay
+		}
+
+		r.steps[i].record(latency, kind)
+	}
+}
+
+// Dropped records an iteration that the executor could not start.
+func (r *LoadRun) Dropped() {
+	atomic.AddInt64(&r.dropped, 1)
+}
+
+// requestErrorKind groups request errors for the error breakdown.
+func requestErrorKind(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "connection refused"
+	case errors.Is(err, syscall.ECONNRESET):
+		return "connection reset"
+	}
+	return "request error"
+}
+
+// StepResult holds the measurements of one step of a load test.
+type StepResult struct {
+	Name     string
+	Method   string
+	Template string
+
+	Requests int64
+	Errors   int64
+
+	// ErrorKinds counts errors by kind, such as "status 500" or "timeout".
+	ErrorKinds map[string]int64
+
+	// Latency is the histogram of response times, including failed requests.
+	Latency *Histogram
+
+	// Throughput is the requests per second over the whole run.
+	Throughput float64
+
+	mu sync.Mutex
+}
+
+func (s *StepResult) record(latency time.Duration, kind string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Requests++
+	s.Latency.Record(latency)
+	if kind != "" {
+		s.Errors++
+		s.ErrorKinds[kind]++
+	}
+}
+
+// ErrorRate returns the fraction of requests that failed.
+func (s *StepResult) ErrorRate() float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return float64(s.Errors) / float64(s.Requests)
+}
+
+// LoadResult is the outcome of a load test.
+type LoadResult struct {
+	Name       string
+	Elapsed    time.Duration
+	Iterations int64
+
+	// DroppedIterations are iterations the executor could not start, for
+	// example because too many were already in flight.
+	DroppedIterations int64
+
+	Steps []*StepResult
+}
+
+// Thresholds are the pass or fail criteria of a load test, checked for each
+// step. Zero values are not checked.
+type Thresholds struct {
+	P95       time.Duration
+	P99       time.Duration
+	ErrorRate float64
+}
+
+// Check returns an error for each step over a threshold.
+func (r *LoadResult) Check(th Thresholds) []error {
+	var errs []error
+	for _, s := range r.Steps {
+		if p := s.Latency.Percentile(95); th.P95 > 0 && p > th.P95 {
+			errs = append(errs, fmt.Errorf("%s: expected p95 within %s, actual %s", s.Name, th.P95, p))
+		}
+		if p := s.Latency.Percentile(99); th.P99 > 0 && p > th.P99 {
+			errs = append(errs, fmt.Errorf("%s: expected p99 within %s, actual %s", s.Name, th.P99, p))
+		}
+		if rate := s.ErrorRate(); th.ErrorRate > 0 && rate > th.ErrorRate {
+			errs = append(errs, fmt.Errorf("%s: expected error rate at most %.2f%%, actual %.2f%%", s.Name, th.ErrorRate*100, rate*100))
+		}
+	}
+	return errs
+}
+
+// WriteTable writes the per step measurements as an aligned table.
+func (r *LoadResult) WriteTable(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STEP\tREQS\tRPS\tP50 ms\tP95 ms\tP99 ms\tMAX ms\tERRORS")
+	for _, s := range r.Steps {
+		fmt.Fprintf(tw, "%s\t%d\t%.1f\t%s\t%s\t%s\t%s\t%.1f%%",
+			s.Name, s.Requests, s.Throughput, millis(s.Latency.Percentile(50)), millis(s.Latency.Percentile(95)),
+			millis(s.Latency.Percentile(99)), millis(s.Latency.Max()), s.ErrorRate()*100)
+		kinds := make([]string, 0, len(s.ErrorKinds))
+		for kind := range s.ErrorKinds {
+			kinds = append(kinds, kind)
+		}
+		sort.Strings(kinds)
+		for _, kind := range kinds {
+			fmt.Fprintf(tw, " %s: %d", kind, s.ErrorKinds[kind])
+		}
+		fmt.Fprintln(tw)
+	}
+	fmt.Fprintf(tw, "%d iterations, %d dropped in %s\n", r.Iterations, r.DroppedIterations, r.Elapsed.Round(time.Millisecond))
+	tw.Flush()
+}
+
+// RunLoad runs the scenario with the executor using client, or a client with
+// a connection pool sized for the executor if nil.
+func RunLoad(client *http.Client, s *Scenario, e Executor) (*LoadResult, error) {
+	if len(s.Steps) == 0 {
+		return nil, fmt.Errorf("scenario %s has no steps", s.Name)
+	}
+
+	if client == nil {
+		transport := baseTransport.Clone()
+		transport.MaxIdleConnsPerHost = e.concurrency()
+		client = &http.Client{Transport: transport}
+	}
+
+	run := &LoadRun{scenario: s, client: client}
+	for _, step := range s.Steps {
+		run.steps = append(run.steps, &StepResult{
+			Name:       step.name(),
+			Method:     step.Endpoint.Method,
+			Template:   step.Endpoint.Path,
+			ErrorKinds: map[string]int64{},
+			Latency:    NewHistogram(),
+		})
+	}
+
+	start := time.Now()
+	if err := e.Execute(run); err != nil {
+		return nil, err
+	}
+	elapsed := time.Since(start)
+
+	for _, step := range run.steps {
+		step.Throughput = float64(step.Requests) / elapsed.Seconds()
+	}
+
+	return &LoadResult{
+		Name:              s.Name,
+		Elapsed:           elapsed,
+		Iterations:        run.iterations,
+		DroppedIterations: run.dropped,
+		Steps:             run.steps,
+	}, nil
+}
+
+// Load adds a sub-test named after the scenario that runs it as a load test
+// with the executor. The sub-test fails if the load test cannot run or is
+// over any of the thresholds, and keeps the measurements in LoadResult. Requests
+// use the test's client when it has a custom transport.
+func (t *Test) Load(s *Scenario, e Executor, th Thresholds) *Test {
+	testCase := t.NewTest(s.Name)
+
+	var client *http.Client
+	if t.Client != nil && t.Client.Transport != nil {
+		client = t.Client
+	}
+
+	result, err := RunLoad(client, s, e)
+	if err != nil {
+		testCase.Error = err
+		return testCase
+	}
+	testCase.LoadResult = result
+	testCase.Duration = result.Elapsed
+
+	if errs := result.Check(th); len(errs) > 0 {
+		testCase.Error = errs[0]
+		testCase.Errors = errs[1:]
+	}
+
+	return testCase
+}
@@ -0,0 +1,338 @@
+package irest
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// loadRequests counts the requests countLoad served.
+var loadRequests int64
+
+// countLoad counts the requests it serves and fails every fourth to /flaky.
+func countLoad(w http.ResponseWriter, r *http.Request) {
+	n := atomic.AddInt64(&loadRequests, 1)
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/load/flaky") && n%4 == 0:
+		w.WriteHeader(http.StatusInternalServerError)
+	case r.Method == http.MethodPost:
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func TestVirtualUsersTarget(t *testing.T) {
+	v := VirtualUsers{
+		Stages: []Stage{
+			{Duration: 10 * time.Second, Target: 10},
+			{Duration: 10 * time.Second, Target: 10},
+			{Duration: 10 * time.Second, Target: 0},
+		},
+	}
+
+	var targetTests = []struct {
+		elapsed time.Duration
+		target  int
+	}{
+		{0, 0},
+		{5 * time.Second, 5},
+		{15 * time.Second, 10},
+		{25 * time.Second, 5},
+		{time.Minute, 0},
+	}
+
+	for _, tt := range targetTests {
+		if target := v.target(tt.elapsed); target != tt.target {
+			t.Errorf("expected %d virtual users after %s, got %d", tt.target, tt.elapsed, target)
+		}
+	}
+
+	if v.concurrency() != 10 || v.duration() != 30*time.Second {
+		t.Errorf("unexpected concurrency %d and duration %s", v.concurrency(), v.duration())
+	}
+}
+
+func TestRunLoadIterations(t *testing.T) {
+	before := atomic.LoadInt64(&loadRequests)
+
+	create := &Endpoint{Path: "/things", Method: http.MethodPost}
+	get := &Endpoint{Path: "/things/%d", Method: http.MethodGet}
+
+	scenario := &Scenario{
+		Name:    "create and get",
+		BaseURL: api.URL + "/load",
+		Steps: []*ScenarioStep{
+			{Endpoint: create, Payload: SampleObject{Name: "load"}, Status: http.StatusCreated},
+			{Endpoint: get, Args: func(i int) []interface{} { return []interface{}{i} }},
+		},
+	}
+
+	result, err := RunLoad(nil, scenario, VirtualUsers{VUs: 4, Iterations: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if requests := atomic.LoadInt64(&loadRequests) - before; result.Iterations != 20 || requests != 40 {
+		t.Errorf("expected 20 iterations and 40 requests, got %d and %d", result.Iterations, requests)
+	}
+
+	if len(result.Steps) != 2 || result.Steps[1].Name != "GET /things/%d" {
+		t.Fatalf("unexpected steps %+v", result.Steps)
+	}
+
+	for _, s := range result.Steps {
+		if s.Requests != 20 || s.Errors != 0 || s.Latency.Count() != 20 || s.Throughput <= 0 {
+			t.Errorf("unexpected step result %s: %d requests, %d errors, %.1f rps", s.Name, s.Requests, s.Errors, s.Throughput)
+		}
+	}
+
+	if errs := result.Check(Thresholds{P95: time.Second, ErrorRate: 0.01}); len(errs) != 0 {
+		t.Errorf("expected thresholds to pass, got %v", errs)
+	}
+}
+
+func TestRunLoadDurationErrors(t *testing.T) {
+	scenario := &Scenario{
+		Name:    "flaky",
+		BaseURL: api.URL + "/load",
+		Steps:   []*ScenarioStep{{Endpoint: &Endpoint{Path: "/flaky", Method: http.MethodGet}}},
+	}
+
+	result, err := RunLoad(nil, scenario, VirtualUsers{VUs: 2, Duration: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	step := result.Steps[0]
+	if step.Requests < 4 || step.Errors == 0 || step.ErrorKinds["status 500"] != step.Errors {
+		t.Errorf("expected status 500 errors, got %d of %d: %v", step.Errors, step.Requests, step.ErrorKinds)
+	}
+
+	if result.Elapsed < 100*time.Millisecond {
+		t.Errorf("expected to run for the duration, ran %s", result.Elapsed)
+	}
+
+	errs := result.Check(Thresholds{ErrorRate: 0.1, P99: time.Nanosecond})
+	if len(errs) != 2 {
+		t.Errorf("expected error rate and p99 thresholds to fail, got %v", errs)
+	}
+}
+
+func TestRunLoadTimeouts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+
+	scenario := &Scenario{
+		Name:    "outage",
+		BaseURL: server.URL,
+		Steps:   []*ScenarioStep{{Endpoint: &Endpoint{Path: "/slow", Method: http.MethodGet}}},
+	}
+
+	client := &http.Client{Timeout: 50 * time.Millisecond}
+	result, err := RunLoad(client, scenario, VirtualUsers{VUs: 2, Iterations: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	step := result.Steps[0]
+	if step.ErrorKinds["timeout"] != 4 {
+		t.Fatalf("expected 4 timeouts, got %v", step.ErrorKinds)
+	}
+	if p := step.Latency.Percentile(99); p < 50*time.Millisecond {
+		t.Errorf("expected timed out requests recorded at their timeout, p99 %s", p)
+	}
+
+	errs := result.Check(Thresholds{P99: 10 * time.Millisecond})
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "p99") {
+		t.Errorf("expected the p99 threshold to fail, got %v", errs)
+	}
+}
+
+func TestRunLoadInvalid(t *testing.T) {
+	scenario := &Scenario{Name: "empty"}
+	if _, err := RunLoad(nil, scenario, VirtualUsers{VUs: 1, Iterations: 1}); err == nil {
+		t.Error("expected an error for a scenario without steps")
+	}
+
+	scenario.Steps = []*ScenarioStep{{Endpoint: &Endpoint{Path: "/", Method: http.MethodGet}}}
+	if _, err := RunLoad(nil, scenario, VirtualUsers{VUs: 1}); err == nil {
+		t.Error("expected an error without a duration or iterations")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	scenario := &Scenario{
+		Name:    "load",
+		BaseURL: api.URL + "/load",
+		Steps:   []*ScenarioStep{{Endpoint: &Endpoint{Path: "/flaky", Method: http.MethodGet}}},
+	}
+
+	test := NewTest("unit-test")
+	load := test.Load(scenario, VirtualUsers{VUs: 2, Iterations: 20}, Thresholds{ErrorRate: 0.1})
+
+	if load.Error == nil || !strings.Contains(load.Error.Error(), "error rate") {
+		t.Errorf("expected error rate threshold to fail, got %v", load.Error)
+	}
+
+	if load.LoadResult == nil || load.LoadResult.Iterations != 20 {
+		t.Fatal("expected load result to be kept on the test")
+	}
+
+	var b bytes.Buffer
+	NewPlainCommandLineReport(test).WriteReport(&b)
+
+	if !strings.Contains(b.String(), "STEP") || !strings.Contains(b.String(), "status 500: 5") {
+		t.Error("expected load table in report output:", b.String())
+	}
+}
+
+func TestLoadPassed(t *testing.T) {
+	scenario := &Scenario{
+		Name:    "load",
+		BaseURL: api.URL + "/load",
+		Steps:   []*ScenarioStep{{Endpoint: &Endpoint{Path: "/things", Method: http.MethodGet}}},
+	}
+
+	test := NewTest("unit-test")
+	load := test.Load(scenario, VirtualUsers{VUs: 2, Iterations: 10}, Thresholds{ErrorRate: 0.1})
+	if load.Error != nil {
+		t.Fatal(load.Error)
+	}
+
+	report := NewPlainCommandLineReport(test)
+
+	var b bytes.Buffer
+	if err := report.WriteReport(&b); err != nil {
+		t.Fatal(err)
+	}
+	output := b.String()
+
+	if !strings.Contains(output, "[PASS]") || !strings.Contains(output, "load\n") || strings.Contains(output, "[] []") {
+		t.Error("expected the load test reported as passed without request fields:", output)
+	}
+	if !strings.Contains(output, "STEP") {
+		t.Error("expected load table in report output:", output)
+	}
+	if !strings.Contains(output, "1 passed, 0 failed") {
+		t.Error("expected the load test counted in the summary:", output)
+	}
+
+	stats, err := report.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 0 {
+		t.Errorf("expected no endpoint stats for the load test, got %+v", stats)
+	}
+}
+
+func TestConstantArrivalRate(t *testing.T) {
+	before := atomic.LoadInt64(&loadRequests)
+
+	scenario := &Scenario{
+		Name:    "arrival rate",
+		BaseURL: api.URL + "/load",
+		Steps:   []*ScenarioStep{{Endpoint: &Endpoint{Path: "/things", Method: http.MethodGet}}},
+	}
+
+	result, err := RunLoad(nil, scenario, ConstantArrivalRate{Rate: 100, Duration: 200 * time.Millisecond, MaxInFlight: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if requests := atomic.LoadInt64(&loadRequests) - before; result.Iterations != 20 || result.DroppedIterations != 0 || requests != 20 {
+		t.Errorf("expected 20 iterations without drops, got %d and %d dropped", result.Iterations, result.DroppedIterations)
+	}
+
+	if result.Elapsed < 190*time.Millisecond {
+		t.Errorf("expected iterations to be spread over the duration, ran %s", result.Elapsed)
+	}
+}
+
+func TestConstantArrivalRateDropped(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+
+	scenario := &Scenario{
+		Name:    "blocked",
+		BaseURL: server.URL,
+		Steps:   []*ScenarioStep{{Endpoint: &Endpoint{Path: "/", Method: http.MethodGet}}},
+	}
+
+	time.AfterFunc(300*time.Millisecond, func() { close(release) })
+
+	result, err := RunLoad(nil, scenario, ConstantArrivalRate{Rate: 50, Duration: 200 * time.Millisecond, MaxInFlight: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Iterations != 2 || result.DroppedIterations != 8 {
+		t.Errorf("expected 2 iterations and 8 dropped, got %d and %d", result.Iterations, result.DroppedIterations)
+	}
+
+	// The second iteration was scheduled 20ms in and waited until release.
+	if p := result.Steps[0].Latency.Min(); p < 250*time.Millisecond {
+		t.Errorf("expected latency to include the time blocked, got %s", p)
+	}
+}
+
+// lateExecutor runs a single iteration scheduled a second ago.
+type lateExecutor struct{}
+
+func (lateExecutor) concurrency() int { return 1 }
+
+func (lateExecutor) Execute(run *LoadRun) error {
+	run.Iterate(time.Now().Add(-time.Second))
+	return nil
+}
+
+func TestIterateCoordinatedOmission(t *testing.T) {
+	get := &Endpoint{Path: "/things", Method: http.MethodGet}
+	scenario := &Scenario{
+		Name:    "late",
+		BaseURL: api.URL + "/load",
+		Steps:   []*ScenarioStep{{Endpoint: get}, {Endpoint: get}},
+	}
+
+	result, err := RunLoad(nil, scenario, lateExecutor{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d := result.Steps[0].Latency.Max(); d < time.Second {
+		t.Errorf("expected first step latency from the scheduled start, got %s", d)
+	}
+
+	if d := result.Steps[1].Latency.Max(); d >= time.Second {
+		t.Errorf("expected later steps to be measured from their own start, got %s", d)
+	}
+}
+
+func TestConstantArrivalRateInvalid(t *testing.T) {
+	scenario := &Scenario{
+		Name:  "invalid",
+		Steps: []*ScenarioStep{{Endpoint: &Endpoint{Path: "/", Method: http.MethodGet}}},
+	}
+
+	for _, e := range []Executor{
+		ConstantArrivalRate{Duration: time.Second},
+		ConstantArrivalRate{Rate: 10},
+	} {
+		if _, err := RunLoad(nil, scenario, e); err == nil {
+			t.Errorf("expected an error for %+v", e)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package irest
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// markdownSlowest is how many of the slowest endpoints the Markdown report
+// lists.
+const markdownSlowest = 5
+
+// WriteMarkdown writes a summary of the run as GitHub flavored Markdown,
+// suitable for posting to a pull request: a table of counts, the failed tests
+// with their errors and the endpoints with the slowest 90th percentile
+// latency, requests grouped by method and path as in Report.Stats.
+func (r *Report) WriteMarkdown(w io.Writer) error {
+	if r.Test == nil {
+		return fmt.Errorf("Report.Test must be set")
+	}
+
+	root := newResult(r.Test, "", 0)
+	summary := root.summary()
+	requests := root.requests()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "## %s\n\n", markdownEscape(root.Name))
+	b.WriteString("| Tests | Passed | Failed | Skipped | Slow | Duration |\n")
+	b.WriteString("| ---: | ---: | ---: | ---: | ---: | ---: |\n")
+	fmt.Fprintf(&b, "| %d | %d | %d | %d | %d | %s |\n",
+		summary.Tests, summary.Passed, summary.Failed, summary.Skipped, summary.Slow, summary.Duration)
+
+	var failed []*result
+	for _, t := range requests {
+		if t.failed() {
+			failed = append(failed, t)
+		}
+	}
+
+	if len(failed) > 0 {
+		b.WriteString("\n### Failed\n\n")
+		b.WriteString("| Test | Request | Status | Error |\n")
+		b.WriteString("| --- | --- | ---: | --- |\n")
+		for _, t := range failed {
+			fmt.Fprintf(&b, "| %s | `%s %s` | %d | %s |\n",
+				markdownEscape(t.Path), t.Method, markdownEscape(t.Endpoint), t.Status,
+				markdownEscape(strings.Join(t.failures(), "; ")))
+		}
+	}
+
+	slowest := endpointStats(root, r.Test.treeBudgets())
+	sort.SliceStable(slowest, func(i, j int) bool {
+		return slowest[i].P90 > slowest[j].P90
+	})
+	if len(slowest) > markdownSlowest {
+		slowest = slowest[:markdownSlowest]
+	}
+
+	if len(slowest) > 0 {
+		b.WriteString("\n### Slowest endpoints\n\n")
+		b.WriteString("| Endpoint | Requests | Errors | Mean | P90 | Max |\n")
+		b.WriteString("| --- | ---: | ---: | ---: | ---: | ---: |\n")
+		for _, s := range slowest {
+			fmt.Fprintf(&b, "| `%s %s` | %d | %d | %s | %s | %s |\n",
+				s.Method, markdownEscape(s.Template), s.Count, s.Errors, s.Mean, s.P90, s.Max)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// markdownEscape keeps a value on a single table cell.
+func markdownEscape(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ", "`", "'").Replace(s)
+}
@@ -0,0 +1,55 @@
+package irest
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestWriteMarkdown(t *testing.T) {
+	test := NewTest("unit-test")
+	test.NewTest("get").Get(api.URL, "/tests").MustStatus(http.StatusOK)
+	test.NewTest("failed | get").Get(api.URL, "/tests").MustStatus(http.StatusNotFound)
+	test.NewTest("skipped").Skip().Get(api.URL, "/tests")
+	test.NewTest("get again").Get(api.URL, "/tests")
+
+	var b bytes.Buffer
+	if err := NewPlainCommandLineReport(test).WriteMarkdown(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	output := b.String()
+	expected := []string{
+		"## unit-test\n\n| Tests | Passed | Failed | Skipped | Slow | Duration |\n",
+		"| 4 | 2 | 1 | 1 | 0 |",
+		"### Failed\n",
+		"| unit-test/failed \\| get | `GET /tests` | 200 | expected status code response of 404, actual 200 |\n",
+		"### Slowest endpoints\n",
+		"| `GET /tests` | 3 | 1 |",
+	}
+
+	for _, expect := range expected {
+		if !strings.Contains(output, expect) {
+			t.Errorf("markdown output should contain %q, got:\n%s", expect, output)
+		}
+	}
+
+	if strings.Contains(output, "unit-test/skipped") || strings.Count(output, "`GET /tests` | 3") != 1 {
+		t.Error("expected requests listed once as their endpoint, without skipped tests:", output)
+	}
+}
+
+func TestWriteMarkdownAllPassed(t *testing.T) {
+	test := NewTest("unit-test")
+	test.NewTest("get").Get(api.URL, "/tests")
+
+	var b bytes.Buffer
+	if err := NewPlainCommandLineReport(test).WriteMarkdown(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(b.String(), "### Failed") {
+		t.Error("expected no failed section:", b.String())
+	}
+}
@@ -0,0 +1,207 @@
+package irest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Middleware wraps the transport requests are made with, to change requests
+// before they are sent or responses before they are checked.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc adapts a function to an http.RoundTripper.
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip calls the function.
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Before is middleware calling the hook before each request is sent. The
+// hook may change the request, and an error stops it from being sent.
+func Before(hook func(*http.Request) error) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if err := hook(req); err != nil {
+				return nil, err
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// After is middleware calling the hook with each response received. An error
+// from the hook is returned as the request's error.
+func After(hook func(*http.Request, *http.Response) error) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			res, err := next.RoundTrip(req)
+			if err != nil {
+				return nil, err
+			}
+			if err := hook(req, res); err != nil {
+				res.Body.Close()
+				return nil, err
+			}
+			return res, nil
+		})
+	}
+}
+
+// RequestID is middleware setting the header, X-Request-ID when empty, to a
+// random ID on requests that do not have one, so requests can be correlated
+// with server logs.
+func RequestID(header string) Middleware {
+	if header == "" {
+		header = "X-Request-ID"
+	}
+
+	return Before(func(req *http.Request) error {
+		if req.Header.Get(header) != "" {
+			return nil
+		}
+
+		id := make([]byte, 16)
+		if _, err := rand.Read(id); err != nil {
+			return err
+		}
+		req.Header.Set(header, hex.EncodeToString(id))
+		return nil
+	})
+}
+
+// LogTraffic is middleware writing each request and response, headers and
+// bodies included, to w. Values of DefaultRedactedHeaders are hidden.
+func LogTraffic(w io.Writer) Middleware {
+	var mu sync.Mutex
+	redact := redactSet(DefaultRedactedHeaders)
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			reqDump, err := httputil.DumpRequestOut(req, true)
+			if err != nil {
+				return nil, err
+			}
+
+			res, err := next.RoundTrip(req)
+			elapsed := time.Since(start)
+
+			var b strings.Builder
+			fmt.Fprintf(&b, "--> %s %s\n%s\n", req.Method, req.URL, redactDump(reqDump, redact))
+			if err != nil {
+				fmt.Fprintf(&b, "<-- %s (%s)\n\n", err, elapsed.Round(time.Millisecond))
+			} else {
+				resDump, dumpErr := httputil.DumpResponse(res, true)
+				if dumpErr != nil {
+					res.Body.Close()
+					return nil, dumpErr
+				}
+				fmt.Fprintf(&b, "<-- %s (%s)\n%s\n", res.Status, elapsed.Round(time.Millisecond), redactDump(resDump, redact))
+			}
+
+			mu.Lock()
+			io.WriteString(w, b.String())
+			mu.Unlock()
+
+			return res, err
+		})
+	}
+}
+
+// redactDump hides the values of redacted headers in a dumped message.
+func redactDump(dump []byte, redact map[string]bool) string {
+	head, body := string(dump), ""
+	if i := strings.Index(head, "\r\n\r\n"); i >= 0 {
+		head, body = head[:i], head[i+4:]
+	}
+
+	lines := strings.Split(head, "\r\n")
+	for i, line := range lines {
+		if name := strings.SplitN(line, ":", 2); i > 0 && len(name) == 2 && redact[http.CanonicalHeaderKey(name[0])] {
+			lines[i] = name[0] + ": " + redacted
+		}
+	}
+
+	out := strings.Join(lines, "\n")
+	if body != "" {
+		out += "\n\n" + body
+	}
+	return strings.TrimRight(out, "\n") + "\n"
+}
+
+// chain wraps the transport in the middleware, the first being outermost so
+// its before hooks run first and its after hooks last.
+func chain(transport http.RoundTripper, middleware []Middleware) http.RoundTripper {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		transport = middleware[i](transport)
+	}
+	return transport
+}
+
+// AddMiddleware adds middleware to the requests of the test and of sub-tests
+// created after it. Middleware runs in the order added.
+func (t *Test) AddMiddleware(m ...Middleware) *Test {
+	if t.Client == nil {
+		t.Client = &http.Client{}
+	}
+	if t.transport == nil {
+		t.transport = t.Client.Transport
+		if t.transport == nil {
+			t.transport = baseTransport
+		}
+	}
+
+	// Copied so middleware added here does not reach the parent test.
+	t.middleware = append(t.middleware[:len(t.middleware):len(t.middleware)], m...)
+	t.rebuildClient()
+
+	return t
+}
+
+// rebuildClient gives the test its own client with the middleware chained
+// over its transport, leaving the clients of other tests unchanged.
+func (t *Test) rebuildClient() {
+	client := *t.Client
+	client.Transport = chain(t.transport, t.middleware)
+	t.Client = &client
+}
+
+// In binds the endpoint test to a test, so its request is made with the
+// test's client, middleware, signer, request compression and accepted
+// encodings, includes the
+// test's headers and cookies, and can use values saved in the test.
+func (e *EndpointTest) In(t *Test) *EndpointTest {
+	e.Parent = t
+	e.Client = t.Client
+	if e.Signer == nil {
+		e.Signer = t.Signer
+	}
+	if e.requestEncoding == "" {
+		e.requestEncoding = t.requestEncoding
+	}
+	if e.acceptEncoding == "" {
+		e.acceptEncoding = t.acceptEncoding
+	}
+
+	if e.Header == nil {
+		e.Header = &http.Header{}
+	}
+	if t.Header != nil {
+		for name, values := range *t.Header {
+			if _, ok := (*e.Header)[name]; !ok {
+				(*e.Header)[name] = append([]string(nil), values...)
+			}
+		}
+	}
+	e.Cookies = append(append([]*http.Cookie(nil), t.Cookies...), e.Cookies...)
+
+	return e
+}
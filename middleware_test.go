@@ -0,0 +1,144 @@
+package irest
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// echoHeaders responds with the request headers it received as JSON.
+func echoHeaders(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"requestId":%q,"authorization":%q,"name":%q}`,
+		r.Header.Get("X-Request-ID"), r.Header.Get("Authorization"), r.Header.Get("name"))
+}
+
+type echoed struct {
+	RequestID     string `json:"requestId"`
+	Authorization string `json:"authorization"`
+	Name          string `json:"name"`
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	var calls []string
+	record := func(name string) Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return Before(func(*http.Request) error {
+				calls = append(calls, "before "+name)
+				return nil
+			})(After(func(*http.Request, *http.Response) error {
+				calls = append(calls, "after "+name)
+				return nil
+			})(next))
+		}
+	}
+
+	test := NewTest("middleware").AddMiddleware(record("first"), record("second"))
+	test.Get(api.URL, "/echo").MustStatus(http.StatusOK)
+	if test.Error != nil {
+		t.Fatal(test.Error)
+	}
+
+	expected := "before first, before second, after second, after first"
+	if actual := strings.Join(calls, ", "); actual != expected {
+		t.Errorf("expected %s, got %s", expected, actual)
+	}
+}
+
+func TestMiddlewareErrors(t *testing.T) {
+	test := NewTest("before").AddMiddleware(Before(func(*http.Request) error {
+		return fmt.Errorf("not allowed")
+	}))
+	test.Get(api.URL, "/echo")
+	if test.Error == nil || !strings.Contains(test.Error.Error(), "not allowed") {
+		t.Errorf("expected the before hook error, got %v", test.Error)
+	}
+
+	test = NewTest("after").AddMiddleware(After(func(_ *http.Request, res *http.Response) error {
+		return fmt.Errorf("unexpected %s", res.Status)
+	}))
+	test.Get(api.URL, "/echo")
+	if test.Error == nil || !strings.Contains(test.Error.Error(), "unexpected 200 OK") {
+		t.Errorf("expected the after hook error, got %v", test.Error)
+	}
+}
+
+func TestMiddlewareInherited(t *testing.T) {
+	rewrite := func(header, value string) Middleware {
+		return Before(func(req *http.Request) error {
+			req.Header.Set(header, value)
+			return nil
+		})
+	}
+
+	root := NewTest("root").AddMiddleware(rewrite("Authorization", "root"))
+	child := root.NewTest("child").AddMiddleware(rewrite("name", "child"))
+
+	var fromChild, fromRoot echoed
+	child.Get(api.URL, "/echo").ParseResponseBody(&fromChild)
+	root.Get(api.URL, "/echo").ParseResponseBody(&fromRoot)
+
+	if fromChild.Authorization != "root" || fromChild.Name != "child" {
+		t.Errorf("expected the child to run root and child middleware, got %+v", fromChild)
+	}
+
+	if fromRoot.Name != "" {
+		t.Errorf("expected child middleware not to reach the root, got %+v", fromRoot)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	test := NewTest("request id").AddMiddleware(RequestID(""))
+
+	var first, second echoed
+	test.NewTest("first").Get(api.URL, "/echo").ParseResponseBody(&first)
+	test.NewTest("second").Get(api.URL, "/echo").ParseResponseBody(&second)
+
+	if len(first.RequestID) != 32 || first.RequestID == second.RequestID {
+		t.Errorf("expected distinct request IDs, got %q and %q", first.RequestID, second.RequestID)
+	}
+
+	kept := NewTest("kept").AddMiddleware(RequestID("")).AddHeader("X-Request-ID", "abc")
+	var echo echoed
+	kept.Get(api.URL, "/echo").ParseResponseBody(&echo)
+	if echo.RequestID != "abc" {
+		t.Errorf("expected an existing request ID to be kept, got %q", echo.RequestID)
+	}
+}
+
+func TestLogTraffic(t *testing.T) {
+	var log bytes.Buffer
+	test := NewTest("log").AddMiddleware(LogTraffic(&log)).AddHeader("Authorization", "Bearer secret")
+	test.Post(api.URL, "/echo/things", map[string]string{"name": "thing"}).MustStatus(http.StatusOK)
+	if test.Error != nil {
+		t.Fatal(test.Error)
+	}
+
+	out := log.String()
+	for _, expected := range []string{"--> POST " + api.URL + "/echo/things", `{"name":"thing"}`, "<-- 200 OK", "Authorization: [REDACTED]", `"authorization":"Bearer secret"`} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected traffic log to contain %q, got:\n%s", expected, out)
+		}
+	}
+
+	if strings.Contains(out, "Authorization: Bearer") {
+		t.Errorf("expected the authorization header to be redacted, got:\n%s", out)
+	}
+}
+
+func TestEndpointTestIn(t *testing.T) {
+	test := NewTest("endpoints").AddMiddleware(RequestID("")).AddHeader("name", "value")
+	get := &Endpoint{Path: "/echo/things", Method: http.MethodGet}
+
+	var echo echoed
+	e := get.Use(api.URL, nil).In(test).Do().MustStatus(http.StatusOK).ParseResponseBody(&echo)
+	if e.Error != nil {
+		t.Fatal(e.Error)
+	}
+
+	if echo.RequestID == "" || echo.Name != "value" {
+		t.Errorf("expected the test's middleware and headers, got %+v", echo)
+	}
+}
@@ -0,0 +1,402 @@
+package irest
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Patch is a patch payload that can also be applied to a JSON document, to
+// check the result of a PATCH request.
+type Patch interface {
+	BodyEncoder
+	Apply(doc []byte) ([]byte, error)
+}
+
+// PatchOperation is one operation of a JSON Patch.
+type PatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	From  string      `json:"from,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// MarshalJSON writes the value of the operations that take one, even when
+// it is null.
+func (o PatchOperation) MarshalJSON() ([]byte, error) {
+	type operation PatchOperation
+	if o.Op != "add" && o.Op != "replace" && o.Op != "test" {
+		return json.Marshal(operation(o))
+	}
+
+	return json.Marshal(struct {
+		Op    string      `json:"op"`
+		Path  string      `json:"path"`
+		Value interface{} `json:"value"`
+	}{o.Op, o.Path, o.Value})
+}
+
+// JSONPatch is an RFC 6902 JSON Patch document, sent as
+// application/json-patch+json.
+type JSONPatch struct {
+	Operations []PatchOperation
+}
+
+// NewJSONPatch creates an empty JSON Patch.
+func NewJSONPatch() *JSONPatch {
+	return &JSONPatch{Operations: []PatchOperation{}}
+}
+
+func (p *JSONPatch) operation(op PatchOperation) *JSONPatch {
+	p.Operations = append(p.Operations, op)
+	return p
+}
+
+// Add adds the value at the path, inserting into arrays.
+func (p *JSONPatch) Add(path string, value interface{}) *JSONPatch {
+	return p.operation(PatchOperation{Op: "add", Path: path, Value: value})
+}
+
+// Remove removes the value at the path.
+func (p *JSONPatch) Remove(path string) *JSONPatch {
+	return p.operation(PatchOperation{Op: "remove", Path: path})
+}
+
+// Replace replaces the value at the path.
+func (p *JSONPatch) Replace(path string, value interface{}) *JSONPatch {
+	return p.operation(PatchOperation{Op: "replace", Path: path, Value: value})
+}
+
+// Move moves the value at from to the path.
+func (p *JSONPatch) Move(from, path string) *JSONPatch {
+	return p.operation(PatchOperation{Op: "move", From: from, Path: path})
+}
+
+// Copy copies the value at from to the path.
+func (p *JSONPatch) Copy(from, path string) *JSONPatch {
+	return p.operation(PatchOperation{Op: "copy", From: from, Path: path})
+}
+
+// Test requires the value at the path to equal the value for the patch to
+// apply.
+func (p *JSONPatch) Test(path string, value interface{}) *JSONPatch {
+	return p.operation(PatchOperation{Op: "test", Path: path, Value: value})
+}
+
+// EncodeBody encodes the operations.
+func (p *JSONPatch) EncodeBody() (string, []byte, error) {
+	b, err := json.Marshal(p.Operations)
+	return "application/json-patch+json", b, err
+}
+
+// Apply applies the operations in order to the JSON document. If one fails,
+// the document is not changed and the error is returned.
+func (p *JSONPatch) Apply(doc []byte) ([]byte, error) {
+	var v interface{}
+	if err := json.Unmarshal(doc, &v); err != nil {
+		return nil, err
+	}
+
+	for i, op := range p.Operations {
+		var err error
+		if v, err = applyOperation(v, op); err != nil {
+			return nil, fmt.Errorf("patch operation %d, %s %s: %s", i, op.Op, op.Path, err)
+		}
+	}
+
+	return json.Marshal(v)
+}
+
+func applyOperation(doc interface{}, op PatchOperation) (interface{}, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add", "replace", "test":
+		value, err := normalizeJSON(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "test" {
+			actual, err := pointerGet(doc, path)
+			if err != nil {
+				return nil, err
+			}
+			if !reflect.DeepEqual(actual, value) {
+				return nil, fmt.Errorf("test failed, value is %v", actual)
+			}
+			return doc, nil
+		}
+		return pointerSet(doc, path, op.Op, value)
+	case "remove":
+		return pointerSet(doc, path, op.Op, nil)
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		value, err := pointerGet(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "move" {
+			if strings.HasPrefix(op.Path+"/", op.From+"/") && op.Path != op.From {
+				return nil, fmt.Errorf("cannot move %s into itself", op.From)
+			}
+			if doc, err = pointerSet(doc, from, "remove", nil); err != nil {
+				return nil, err
+			}
+		} else if value, err = normalizeJSON(value); err != nil {
+			return nil, err
+		}
+		return pointerSet(doc, path, "add", value)
+	default:
+		return nil, fmt.Errorf("unknown operation")
+	}
+}
+
+// normalizeJSON converts a value to the types JSON documents decode to,
+// copying it.
+func normalizeJSON(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var normalized interface{}
+	err = json.Unmarshal(b, &normalized)
+	return normalized, err
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer into unescaped tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("JSON pointer %q must start with /", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+	return tokens, nil
+}
+
+// arrayIndex parses an array index token, which has no leading zeros.
+func arrayIndex(token string, length int) (int, error) {
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || strconv.Itoa(i) != token || i >= length {
+		return 0, fmt.Errorf("index %s out of range", token)
+	}
+	return i, nil
+}
+
+func pointerGet(doc interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch c := doc.(type) {
+		case map[string]interface{}:
+			v, ok := c[token]
+			if !ok {
+				return nil, fmt.Errorf("member %s not found", token)
+			}
+			doc = v
+		case []interface{}:
+			i, err := arrayIndex(token, len(c))
+			if err != nil {
+				return nil, err
+			}
+			doc = c[i]
+		default:
+			return nil, fmt.Errorf("cannot find %s in a value", token)
+		}
+	}
+	return doc, nil
+}
+
+// pointerSet adds, replaces or removes the value at the path and returns the
+// changed document.
+func pointerSet(doc interface{}, path []string, op string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		if op == "remove" {
+			return nil, fmt.Errorf("cannot remove the whole document")
+		}
+		return value, nil
+	}
+
+	token, last := path[0], len(path) == 1
+	switch c := doc.(type) {
+	case map[string]interface{}:
+		child, ok := c[token]
+		if !last {
+			if !ok {
+				return nil, fmt.Errorf("member %s not found", token)
+			}
+			changed, err := pointerSet(child, path[1:], op, value)
+			c[token] = changed
+			return c, err
+		}
+		if !ok && op != "add" {
+			return nil, fmt.Errorf("member %s not found", token)
+		}
+		if op == "remove" {
+			delete(c, token)
+		} else {
+			c[token] = value
+		}
+		return c, nil
+	case []interface{}:
+		if last && op == "add" {
+			if token == "-" {
+				return append(c, value), nil
+			}
+			i, err := arrayIndex(token, len(c)+1)
+			if err != nil {
+				return nil, err
+			}
+			c = append(c, nil)
+			copy(c[i+1:], c[i:])
+			c[i] = value
+			return c, nil
+		}
+
+		i, err := arrayIndex(token, len(c))
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case !last:
+			changed, err := pointerSet(c[i], path[1:], op, value)
+			c[i] = changed
+			return c, err
+		case op == "remove":
+			return append(c[:i], c[i+1:]...), nil
+		default:
+			c[i] = value
+			return c, nil
+		}
+	default:
+		return nil, fmt.Errorf("cannot find %s in a value", token)
+	}
+}
+
+// MergePatch is an RFC 7396 JSON Merge Patch, sent as
+// application/merge-patch+json. Null members remove the member they patch.
+type MergePatch struct {
+	Patch interface{}
+}
+
+// NewMergePatch creates a merge patch of the value.
+func NewMergePatch(patch interface{}) *MergePatch {
+	return &MergePatch{Patch: patch}
+}
+
+// EncodeBody encodes the patch.
+func (p *MergePatch) EncodeBody() (string, []byte, error) {
+	b, err := json.Marshal(p.Patch)
+	return "application/merge-patch+json", b, err
+}
+
+// Apply merges the patch into the JSON document.
+func (p *MergePatch) Apply(doc []byte) ([]byte, error) {
+	var target interface{}
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, err
+	}
+
+	patch, err := normalizeJSON(p.Patch)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(mergePatch(target, patch))
+}
+
+func mergePatch(target, patch interface{}) interface{} {
+	members, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	doc, ok := target.(map[string]interface{})
+	if !ok {
+		doc = map[string]interface{}{}
+	}
+
+	for name, value := range members {
+		if value == nil {
+			delete(doc, name)
+		} else {
+			doc[name] = mergePatch(doc[name], value)
+		}
+	}
+	return doc
+}
+
+// checkPatched compares a JSON response body with the original document, a
+// value or JSON bytes, with the patch applied.
+func checkPatched(body []byte, original interface{}, patch Patch) error {
+	doc, ok := original.([]byte)
+	if !ok {
+		var err error
+		if doc, err = json.Marshal(original); err != nil {
+			return err
+		}
+	}
+
+	patched, err := patch.Apply(doc)
+	if err != nil {
+		return err
+	}
+
+	var expected, actual interface{}
+	if err := json.Unmarshal(patched, &expected); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, &actual); err != nil {
+		return fmt.Errorf("response body is not JSON: %s", err)
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		return fmt.Errorf("expected patched document %s, actual %s", patched, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// MustEqualPatched sets the Test.Error if the JSON response body does not
+// equal the original document with the patch applied.
+func (t *Test) MustEqualPatched(original interface{}, patch Patch) *Test {
+	if t.Error != nil {
+		return t
+	}
+
+	if t.Response == nil {
+		t.Error = errNoResponse
+		return t
+	}
+
+	t.Error = checkPatched(t.responseBody, original, patch)
+
+	return t
+}
+
+// MustEqualPatched sets the EndpointTest.Error if the JSON response body does
+// not equal the original document with the patch applied.
+func (e *EndpointTest) MustEqualPatched(original interface{}, patch Patch) *EndpointTest {
+	if e.Error != nil {
+		return e
+	}
+
+	if e.Response == nil {
+		e.Error = errNoResponse
+		return e
+	}
+
+	e.Error = checkPatched(e.responseBody, original, patch)
+
+	return e
+}
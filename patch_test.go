@@ -0,0 +1,234 @@
+package irest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// jsonEqual reports whether two JSON documents are equal.
+func jsonEqual(t *testing.T, a, b string) bool {
+	var va, vb interface{}
+	if err := json.Unmarshal([]byte(a), &va); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(b), &vb); err != nil {
+		t.Fatal(err)
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// The examples of RFC 6902 Appendix A.
+func TestJSONPatchRFC6902(t *testing.T) {
+	var patchTests = []struct {
+		name     string
+		doc      string
+		patch    *JSONPatch
+		expected string
+	}{
+		{"A.1", `{"foo":"bar"}`, NewJSONPatch().Add("/baz", "qux"), `{"baz":"qux","foo":"bar"}`},
+		{"A.2", `{"foo":["bar","baz"]}`, NewJSONPatch().Add("/foo/1", "qux"), `{"foo":["bar","qux","baz"]}`},
+		{"A.3", `{"baz":"qux","foo":"bar"}`, NewJSONPatch().Remove("/baz"), `{"foo":"bar"}`},
+		{"A.4", `{"foo":["bar","qux","baz"]}`, NewJSONPatch().Remove("/foo/1"), `{"foo":["bar","baz"]}`},
+		{"A.5", `{"baz":"qux","foo":"bar"}`, NewJSONPatch().Replace("/baz", "boo"), `{"baz":"boo","foo":"bar"}`},
+		{"A.6", `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, NewJSONPatch().Move("/foo/waldo", "/qux/thud"),
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{"A.7", `{"foo":["all","grass","cows","eat"]}`, NewJSONPatch().Move("/foo/1", "/foo/3"), `{"foo":["all","cows","eat","grass"]}`},
+		{"A.8", `{"baz":"qux","foo":["a",2,"c"]}`, NewJSONPatch().Test("/baz", "qux").Test("/foo/1", 2), `{"baz":"qux","foo":["a",2,"c"]}`},
+		{"A.10", `{"foo":"bar"}`, NewJSONPatch().Add("/child", map[string]interface{}{"grandchild": map[string]interface{}{}}),
+			`{"foo":"bar","child":{"grandchild":{}}}`},
+		{"A.14", `{"/":9,"~1":10}`, NewJSONPatch().Test("/~01", 10), `{"/":9,"~1":10}`},
+		{"A.16", `{"foo":["bar"]}`, NewJSONPatch().Add("/foo/-", []string{"abc", "def"}), `{"foo":["bar",["abc","def"]]}`},
+		{"copy", `{"a":{"b":1}}`, NewJSONPatch().Copy("/a", "/c").Replace("/c/b", 2), `{"a":{"b":1},"c":{"b":2}}`},
+		{"root", `{"a":1}`, NewJSONPatch().Replace("", []int{1}), `[1]`},
+	}
+
+	for _, tt := range patchTests {
+		actual, err := tt.patch.Apply([]byte(tt.doc))
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		if !jsonEqual(t, string(actual), tt.expected) {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.expected, actual)
+		}
+	}
+}
+
+func TestJSONPatchErrors(t *testing.T) {
+	var errorTests = []struct {
+		name  string
+		doc   string
+		patch *JSONPatch
+	}{
+		{"A.9 test failed", `{"baz":"qux"}`, NewJSONPatch().Test("/baz", "bar")},
+		{"A.12 missing parent", `{"foo":"bar"}`, NewJSONPatch().Add("/baz/bat", "qux")},
+		{"A.15 string is not number", `{"/":9,"~1":10}`, NewJSONPatch().Test("/~01", "10")},
+		{"remove missing", `{"a":1}`, NewJSONPatch().Remove("/b")},
+		{"replace missing", `{"a":1}`, NewJSONPatch().Replace("/b", 1)},
+		{"leading zero index", `{"a":[1,2]}`, NewJSONPatch().Remove("/a/01")},
+		{"index out of range", `{"a":[1]}`, NewJSONPatch().Add("/a/2", 1)},
+		{"move into itself", `{"a":{"b":1}}`, NewJSONPatch().Move("/a", "/a/b")},
+		{"invalid pointer", `{"a":1}`, NewJSONPatch().Remove("a")},
+		{"unknown op", `{"a":1}`, &JSONPatch{Operations: []PatchOperation{{Op: "rename", Path: "/a"}}}},
+	}
+
+	for _, tt := range errorTests {
+		if _, err := tt.patch.Apply([]byte(tt.doc)); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestJSONPatchEncoding(t *testing.T) {
+	contentType, body, err := NewJSONPatch().Add("/a", nil).Remove("/b").Move("/c", "/d").EncodeBody()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `[{"op":"add","path":"/a","value":null},{"op":"remove","path":"/b"},{"op":"move","path":"/d","from":"/c"}]`
+	if contentType != "application/json-patch+json" || string(body) != expected {
+		t.Errorf("expected %s, got %s %s", expected, contentType, body)
+	}
+}
+
+// The examples of RFC 7396 Appendix A.
+func TestMergePatchRFC7396(t *testing.T) {
+	var mergeTests = []struct {
+		doc, patch, expected string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+
+	for _, tt := range mergeTests {
+		var patch interface{}
+		json.Unmarshal([]byte(tt.patch), &patch)
+
+		actual, err := NewMergePatch(patch).Apply([]byte(tt.doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !jsonEqual(t, string(actual), tt.expected) {
+			t.Errorf("%s merged with %s: expected %s, got %s", tt.doc, tt.patch, tt.expected, actual)
+		}
+	}
+}
+
+// newPatchAPI applies merge patches and JSON patches to a stored document.
+func newPatchAPI(doc string) *httptest.Server {
+	var mu sync.Mutex
+	stored := []byte(doc)
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		w.Header().Set("Allow", "GET, HEAD, PATCH, OPTIONS")
+		switch r.Method {
+		case http.MethodOptions:
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case http.MethodPatch:
+			body, _ := ioutil.ReadAll(r.Body)
+			var patch Patch
+			switch r.Header.Get("Content-Type") {
+			case "application/merge-patch+json":
+				var v interface{}
+				json.Unmarshal(body, &v)
+				patch = NewMergePatch(v)
+			case "application/json-patch+json":
+				p := NewJSONPatch()
+				json.Unmarshal(body, &p.Operations)
+				patch = p
+			default:
+				w.WriteHeader(http.StatusUnsupportedMediaType)
+				return
+			}
+
+			patched, err := patch.Apply(stored)
+			if err != nil {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				return
+			}
+			stored = patched
+		case http.MethodGet, http.MethodHead:
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(stored)
+	}))
+}
+
+func TestPatchRequests(t *testing.T) {
+	original := map[string]interface{}{"title": "Goodbye!", "author": map[string]interface{}{"givenName": "John", "familyName": "Doe"}, "tags": []string{"example", "sample"}}
+	doc, _ := json.Marshal(original)
+
+	server := newPatchAPI(string(doc))
+	defer server.Close()
+
+	test := NewTest("patch")
+	merge := NewMergePatch(map[string]interface{}{"title": "Hello!", "author": map[string]interface{}{"familyName": nil}, "tags": []string{"example"}})
+	test.NewTest("merge").Patch(server.URL, "/doc", merge).MustStatus(http.StatusOK).MustEqualPatched(original, merge)
+
+	patched := test.Tests[0].responseBody
+	jsonPatch := NewJSONPatch().Test("/title", "Hello!").Add("/tags/-", "more").Remove("/author/givenName")
+	test.NewTest("json patch").Patch(server.URL, "/doc", jsonPatch).MustStatus(http.StatusOK).MustEqualPatched(patched, jsonPatch)
+
+	test.NewTest("wrong").Get(server.URL, "/doc").MustEqualPatched(patched, NewMergePatch(map[string]string{"title": "Other"}))
+
+	for _, c := range test.Tests[:2] {
+		if c.Error != nil {
+			t.Errorf("%s: %s", c.Name, c.Error)
+		}
+	}
+	if test.Tests[2].Error == nil {
+		t.Error("expected a mismatch for a patch that was not applied")
+	}
+
+	get := &Endpoint{Path: "/doc", Method: http.MethodPatch}
+	e := get.Use(server.URL, merge).Do().MustStatus(http.StatusOK).MustEqualPatched(test.Tests[1].responseBody, merge)
+	if e.Error != nil {
+		t.Error(e.Error)
+	}
+}
+
+func TestMoreMethods(t *testing.T) {
+	server := newPatchAPI(`{"a":1}`)
+	defer server.Close()
+
+	head := NewTest("head").Head(server.URL, "/doc").MustStatus(http.StatusOK).MustContentType("application/json")
+	if head.Error != nil || len(head.responseBody) != 0 || head.Method != http.MethodHead {
+		t.Errorf("expected headers only, got %q, %v", head.responseBody, head.Error)
+	}
+
+	options := NewTest("options").Options(server.URL, "/doc").MustStatus(http.StatusNoContent).MustHeader("Allow", Contains("PATCH"))
+	if options.Error != nil {
+		t.Error(options.Error)
+	}
+
+	request := NewTest("request").Request("PURGE", server.URL, "/doc", nil).MustStatus(http.StatusMethodNotAllowed)
+	if request.Error != nil || request.Method != "PURGE" {
+		t.Errorf("expected a PURGE request, got %s, %v", request.Method, request.Error)
+	}
+}
@@ -0,0 +1,349 @@
+package irest
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// RaceResult holds the responses of identical requests made at once by
+// RaceTest.
+type RaceResult struct {
+	Requests int
+
+	// Statuses counts the responses by status code.
+	Statuses map[int]int
+
+	// Errors counts the requests that got no response.
+	Errors int
+
+	// Spread is the time between the first and the last request being
+	// released, showing how close to simultaneous they were.
+	Spread  time.Duration
+	Elapsed time.Duration
+
+	// Responses are the endpoint tests of each request made.
+	Responses []*EndpointTest
+}
+
+// StatusCount returns the number of responses with the status code.
+func (r *RaceResult) StatusCount(status int) int {
+	return r.Statuses[status]
+}
+
+// WriteTable writes the status distribution of the responses as an aligned
+// table.
+func (r *RaceResult) WriteTable(w io.Writer) {
+	statuses := make([]int, 0, len(r.Statuses))
+	for status := range r.Statuses {
+		statuses = append(statuses, status)
+	}
+	sort.Ints(statuses)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STATUS\tCOUNT")
+	for _, status := range statuses {
+		fmt.Fprintf(tw, "%d\t%d\n", status, r.Statuses[status])
+	}
+	if r.Errors > 0 {
+		fmt.Fprintf(tw, "error\t%d\n", r.Errors)
+	}
+	fmt.Fprintf(tw, "%d requests, spread %s in %s\n", r.Requests, r.Spread, r.Elapsed.Round(time.Millisecond))
+	tw.Flush()
+}
+
+// RaceInvariant checks a condition that must hold over all the responses of
+// a race.
+type RaceInvariant func(*RaceResult) error
+
+// ExactlyOne requires one response with the status and all others with the
+// rejected status, as when only one of several identical submissions may
+// succeed.
+func ExactlyOne(status, rejected int) RaceInvariant {
+	return func(r *RaceResult) error {
+		if r.Statuses[status] != 1 || r.Statuses[status]+r.Statuses[rejected] != r.Requests {
+			return fmt.Errorf("expected exactly one %d and %d %d responses, actual %s", status, r.Requests-1, rejected, r.distribution())
+		}
+		return nil
+	}
+}
+
+// StatusCounts requires the number of responses with each status code to be
+// exactly as expected.
+func StatusCounts(expected map[int]int) RaceInvariant {
+	return func(r *RaceResult) error {
+		total := 0
+		for status, n := range expected {
+			total += n
+			if r.Statuses[status] != n {
+				return fmt.Errorf("expected %d %d responses, actual %s", n, status, r.distribution())
+			}
+		}
+		if total != r.Requests {
+			return fmt.Errorf("expected only statuses %v, actual %s", expected, r.distribution())
+		}
+		return nil
+	}
+}
+
+// distribution formats the status counts for error messages.
+func (r *RaceResult) distribution() string {
+	var b strings.Builder
+	r.WriteTable(&b)
+	return strings.Join(strings.Fields(strings.SplitN(b.String(), "\n", 2)[1]), " ")
+}
+
+// preconnect returns a copy of the client with its own copy of the transport,
+// whose first connection to the URL's host is opened now, so a request
+// released later is sent without waiting to connect. Requests through a
+// proxy, or to other hosts, connect as usual, as do requests when the
+// connection cannot be opened, so they fail with the error themselves.
+func preconnect(client *http.Client, transport *http.Transport, rawURL string) *http.Client {
+	t := transport.Clone()
+	c := *client
+	c.Transport = t
+
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return &c
+	}
+	if t.Proxy != nil {
+		if proxy, _ := t.Proxy(&http.Request{URL: u}); proxy != nil {
+			return &c
+		}
+	}
+	if u.Scheme == "https" && t.DialTLSContext != nil {
+		return &c
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	addr := net.JoinHostPort(u.Hostname(), port)
+
+	dial := t.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{Timeout: 30 * time.Second}).DialContext
+	}
+	conn, err := dial(context.Background(), "tcp", addr)
+	if err != nil {
+		return &c
+	}
+
+	var mu sync.Mutex
+	opened := func(dialAddr string) net.Conn {
+		mu.Lock()
+		defer mu.Unlock()
+		if dialAddr != addr {
+			return nil
+		}
+		opened := conn
+		conn = nil
+		return opened
+	}
+
+	if u.Scheme == "http" {
+		t.DialContext = func(ctx context.Context, network, dialAddr string) (net.Conn, error) {
+			if c := opened(dialAddr); c != nil {
+				return c, nil
+			}
+			return dial(ctx, network, dialAddr)
+		}
+		return &c
+	}
+
+	config := &tls.Config{}
+	if t.TLSClientConfig != nil {
+		config = t.TLSClientConfig.Clone()
+	}
+	if config.ServerName == "" {
+		config.ServerName = u.Hostname()
+	}
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return &c
+	}
+	conn = tlsConn
+
+	t.DialTLSContext = func(ctx context.Context, network, dialAddr string) (net.Conn, error) {
+		if c := opened(dialAddr); c != nil {
+			return c, nil
+		}
+		raw, err := dial(ctx, network, dialAddr)
+		if err != nil {
+			return nil, err
+		}
+		host, _, _ := net.SplitHostPort(dialAddr)
+		hostConfig := config.Clone()
+		hostConfig.ServerName = host
+		tlsConn := tls.Client(raw, hostConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			raw.Close()
+			return nil, err
+		}
+		return tlsConn, nil
+	}
+	return &c
+}
+
+// racer returns a new endpoint test for the request the endpoint test
+// defines, without anything recorded from requests it already made.
+func (e *EndpointTest) racer(client *http.Client, parent *Test) *EndpointTest {
+	r := &EndpointTest{
+		Name:       e.Name,
+		Parent:     parent,
+		Path:       e.Path,
+		URL:        e.URL,
+		Method:     e.Method,
+		Parameters: e.Parameters,
+		Payload:    e.Payload,
+		Client:     client,
+		Signer:     e.Signer,
+		Cookies:    append([]*http.Cookie(nil), e.Cookies...),
+		Header:     &http.Header{},
+
+		requestEncoding: e.requestEncoding,
+		acceptEncoding:  e.acceptEncoding,
+	}
+	if e.Header != nil {
+		header := e.Header.Clone()
+		r.Header = &header
+	}
+	return r
+}
+
+// RaceTest adds a sub-test making n copies of the endpoint test's request at
+// once. Requests are built and their connections opened first, then released
+// together from a barrier, each on its own connection, to expose
+// double-submission bugs. Check the responses with MustRace.
+func (t *Test) RaceTest(e *EndpointTest, n int) *Test {
+	name := e.Name
+	if name == "" {
+		name = fmt.Sprintf("%s %s", e.Method, e.Path)
+	}
+
+	testCase := t.NewTest(fmt.Sprintf("race %d x %s", n, name))
+	testCase.Method = e.Method
+	testCase.Endpoint = e.Path
+	if u, err := url.Parse(e.URL); err == nil && u.Path != "" {
+		testCase.Endpoint = u.Path
+	}
+
+	if n < 2 {
+		testCase.Error = fmt.Errorf("race needs at least 2 requests, got %d", n)
+		return testCase
+	}
+
+	client := e.Client
+	if client == nil {
+		client = &http.Client{}
+	}
+	transport, _ := client.Transport.(*http.Transport)
+	if client.Transport == nil {
+		transport = baseTransport
+	}
+
+	result := &RaceResult{Requests: n, Statuses: map[int]int{}}
+	released := make([]time.Time, n)
+
+	var ready, done sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < n; i++ {
+		racerClient := client
+		if transport != nil {
+			// Other transports, such as middleware or a handler, are shared
+			// and connect once released.
+			racerClient = preconnect(client, transport, e.URL)
+			defer racerClient.CloseIdleConnections()
+		}
+
+		r := e.racer(racerClient, testCase)
+		result.Responses = append(result.Responses, r)
+
+		ready.Add(1)
+		done.Add(1)
+		go func(i int, r *EndpointTest) {
+			defer done.Done()
+
+			err := r.newRequest()
+			ready.Done()
+			<-start
+			if err != nil {
+				r.Error = err
+				return
+			}
+
+			released[i] = time.Now()
+			r.send()
+		}(i, r)
+	}
+
+	ready.Wait()
+	begin := time.Now()
+	close(start)
+	done.Wait()
+	result.Elapsed = time.Since(begin)
+
+	first, last := released[0], released[0]
+	for _, at := range released {
+		if at.IsZero() {
+			continue
+		}
+		if first.IsZero() || at.Before(first) {
+			first = at
+		}
+		if at.After(last) {
+			last = at
+		}
+	}
+	result.Spread = last.Sub(first)
+
+	for _, r := range result.Responses {
+		if r.Response == nil {
+			result.Errors++
+			continue
+		}
+		result.Statuses[r.Response.StatusCode]++
+	}
+
+	testCase.RaceResult = result
+	testCase.Duration = result.Elapsed
+
+	return testCase
+}
+
+// MustRace sets the Test.Error to the first invariant that does not hold over
+// the responses of a race made with RaceTest.
+func (t *Test) MustRace(invariants ...RaceInvariant) *Test {
+	if t.Error != nil {
+		return t
+	}
+
+	if t.RaceResult == nil {
+		t.Error = fmt.Errorf("race result not set, must have RaceTest before checking invariants")
+		return t
+	}
+
+	for _, invariant := range invariants {
+		if err := invariant(t.RaceResult); err != nil {
+			t.Error = err
+			return t
+		}
+	}
+
+	return t
+}
@@ -0,0 +1,198 @@
+package irest
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newOrdersAPI creates orders by idempotency key. When checked is false the
+// check and the insert are locked apart, so concurrent submissions all
+// succeed.
+func newOrdersAPI(checked bool) *httptest.Server {
+	var mu sync.Mutex
+	orders := map[string]bool{}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+
+		mu.Lock()
+		exists := orders[key]
+		if !checked {
+			mu.Unlock()
+		}
+
+		if exists {
+			if checked {
+				mu.Unlock()
+			}
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+
+		time.Sleep(20 * time.Millisecond)
+
+		if !checked {
+			mu.Lock()
+		}
+		orders[key] = true
+		mu.Unlock()
+
+		w.WriteHeader(http.StatusCreated)
+	}))
+}
+
+func newOrderTest(baseURL string) *EndpointTest {
+	create := &Endpoint{Path: "/orders", Method: http.MethodPost}
+	e := create.Use(baseURL, map[string]int{"amount": 10})
+	e.Header.Set("Idempotency-Key", "order-1")
+	return e
+}
+
+func TestRaceTest(t *testing.T) {
+	server := newOrdersAPI(true)
+	defer server.Close()
+
+	test := NewTest("orders")
+	race := test.RaceTest(newOrderTest(server.URL), 5).MustRace(ExactlyOne(http.StatusCreated, http.StatusConflict))
+
+	if race.Error != nil {
+		t.Fatal(race.Error)
+	}
+
+	if race.RaceResult.StatusCount(http.StatusCreated) != 1 || race.RaceResult.StatusCount(http.StatusConflict) != 4 {
+		t.Errorf("unexpected status distribution %v", race.RaceResult.Statuses)
+	}
+
+	if len(race.RaceResult.Responses) != 5 {
+		t.Errorf("expected 5 responses, got %d", len(race.RaceResult.Responses))
+	}
+
+	if race.Method != http.MethodPost || race.Endpoint != "/orders" {
+		t.Errorf("expected race to be reported as POST /orders, got %s %s", race.Method, race.Endpoint)
+	}
+}
+
+func TestRaceTestDoubleSubmission(t *testing.T) {
+	server := newOrdersAPI(false)
+	defer server.Close()
+
+	test := NewTest("orders")
+	race := test.RaceTest(newOrderTest(server.URL), 5).MustRace(ExactlyOne(http.StatusCreated, http.StatusConflict))
+
+	if race.Error == nil {
+		t.Fatal("expected the double submission to break the invariant")
+	}
+
+	if !strings.Contains(race.Error.Error(), "201 5") {
+		t.Errorf("expected the status distribution in the error, got %s", race.Error)
+	}
+}
+
+func TestRaceTestStatusCounts(t *testing.T) {
+	server := newOrdersAPI(true)
+	defer server.Close()
+
+	var invariantTests = []struct {
+		expected map[int]int
+		ok       bool
+	}{
+		{map[int]int{http.StatusCreated: 1, http.StatusConflict: 2}, true},
+		{map[int]int{http.StatusCreated: 3}, false},
+		{map[int]int{http.StatusCreated: 1}, false},
+	}
+
+	for _, tt := range invariantTests {
+		test := NewTest("orders")
+		e := newOrderTest(server.URL)
+		e.Header.Set("Idempotency-Key", time.Now().String())
+
+		race := test.RaceTest(e, 3).MustRace(StatusCounts(tt.expected))
+		if (race.Error == nil) != tt.ok {
+			t.Errorf("expected %v to hold: %t, got %v", tt.expected, tt.ok, race.Error)
+		}
+	}
+}
+
+func TestRaceTestPreconnects(t *testing.T) {
+	server := newOrdersAPI(true)
+	defer server.Close()
+	secure := newTLSAPI(nil)
+	defer secure.Close()
+
+	get := &Endpoint{Path: "/", Method: http.MethodGet}
+	test := NewTest("preconnect").AddRootCA(serverCAPEM(secure))
+	races := []*Test{
+		test.RaceTest(newOrderTest(server.URL), 3),
+		test.RaceTest(test.Use(get, secure.URL, nil), 3),
+	}
+
+	for _, race := range races {
+		for _, r := range race.RaceResult.Responses {
+			if r.Error != nil {
+				t.Errorf("%s: %s", race.Name, r.Error)
+				continue
+			}
+			// The transport still reports the finished TLS handshake of a
+			// connection it is given, so only the connect time shows it.
+			if r.Timing.Connect != 0 || r.Timing.ConnReused {
+				t.Errorf("%s: expected the connection opened before release, actual %s", race.Name, r.Timing)
+			}
+		}
+	}
+}
+
+func TestRaceTestAfterDo(t *testing.T) {
+	server := newOrdersAPI(true)
+
+	e := newOrderTest(server.URL).Do().MustStatus(http.StatusCreated)
+	if e.Error != nil {
+		t.Fatal(e.Error)
+	}
+	server.Close()
+
+	race := NewTest("orders").RaceTest(e, 3)
+	if race.RaceResult.Errors != 3 || len(race.RaceResult.Statuses) != 0 {
+		t.Errorf("expected 3 requests without responses, actual %d errors and statuses %v", race.RaceResult.Errors, race.RaceResult.Statuses)
+	}
+
+	for _, r := range race.RaceResult.Responses {
+		if r.Response != nil || r.Error == nil || r.Timing.Start.Equal(e.Timing.Start) {
+			t.Errorf("expected racer without the endpoint test's response and timing, actual %v, %v, %v", r.Response, r.Error, r.Timing.Start)
+		}
+	}
+}
+
+func TestRaceTestTooFew(t *testing.T) {
+	test := NewTest("orders")
+	race := test.RaceTest(newOrderTest("http://localhost"), 1).MustRace(ExactlyOne(http.StatusCreated, http.StatusConflict))
+
+	if race.Error == nil {
+		t.Error("expected an error for a race of 1 request")
+	}
+}
+
+func TestRaceReport(t *testing.T) {
+	server := newOrdersAPI(true)
+	defer server.Close()
+
+	test := NewTest("orders")
+	test.RaceTest(newOrderTest(server.URL), 3).MustRace(ExactlyOne(http.StatusCreated, http.StatusConflict))
+
+	var out bytes.Buffer
+	report := NewPlainCommandLineReport(test)
+	report.StatsTable = false
+	if err := report.WriteReport(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{"race 3 x POST /orders", "STATUS  COUNT", "201     1", "409     2", "3 requests"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected report to contain %q, got:\n%s", expected, out.String())
+		}
+	}
+}
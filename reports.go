@@ -2,6 +2,10 @@ package irest
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
 )
 
 // Report provides test to output results for as well as various fields for
@@ -10,76 +14,314 @@ type Report struct {
 	InfoLabel     string
 	PassTestLabel string
 	FailTestLabel string
+	SlowTestLabel string
+	SkipTestLabel string
 	TimingHeader  string
 
+	// FastThreshold and SlowThreshold split timings into fast, medium and
+	// slow for display. Zero values use 100ms and 500ms.
+	FastThreshold time.Duration
+	SlowThreshold time.Duration
+
+	// TimingBreakdown prints the DNS, connect, TLS, time to first byte and
+	// download times below each request.
+	TimingBreakdown bool
+
+	// StatsTable prints the latency statistics of each endpoint after the
+	// summary.
+	StatsTable bool
+
+	// RedactHeaders are the headers whose values are hidden in reports that
+	// show requests and responses. Nil uses DefaultRedactedHeaders.
+	RedactHeaders []string
+
+	// NoColor disables ANSI color codes, around timings and in labels, as
+	// the NO_COLOR environment variable does.
+	NoColor bool
+
+	// Output is where PrintResults writes the console report. Nil writes to
+	// stdout.
+	Output io.Writer
+
 	Test *Test
+
+	reporters []reportOutput
+}
+
+// Reporter writes a report of test results to w.
+type Reporter interface {
+	WriteReport(w io.Writer) error
+}
+
+// ReporterFunc adapts a function, such as Report.WriteJUnit, to a Reporter.
+type ReporterFunc func(w io.Writer) error
+
+// WriteReport calls f(w).
+func (f ReporterFunc) WriteReport(w io.Writer) error {
+	return f(w)
+}
+
+type reportOutput struct {
+	w        io.Writer
+	reporter Reporter
 }
 
 // NewColoredCommandLineReport sets outputs to use ANSI color codes
 // and unicode check and x.
 func NewColoredCommandLineReport(t *Test) *Report {
 	return &Report{
-		InfoLabel:     "[ \033[00;96m\xE2\x8B\xAE\033[0m ]",
-		PassTestLabel: "[ \033[00;32m\xE2\x9C\x93\033[0m ]",
-		FailTestLabel: "[ \033[00;31m\xE2\x9C\x98\033[0m ]",
-		TimingHeader:  "[   ms   ]",
-		Test:          t,
+		InfoLabel:       "[ \033[00;96m\xE2\x8B\xAE\033[0m ]",
+		PassTestLabel:   "[ \033[00;32m\xE2\x9C\x93\033[0m ]",
+		FailTestLabel:   "[ \033[00;31m\xE2\x9C\x98\033[0m ]",
+		SlowTestLabel:   "[ \033[00;33m!\033[0m ]",
+		SkipTestLabel:   "[ \033[00;90m-\033[0m ]",
+		TimingHeader:    "[   ms   ]",
+		FastThreshold:   100 * time.Millisecond,
+		SlowThreshold:   500 * time.Millisecond,
+		TimingBreakdown: true,
+		StatsTable:      true,
+		Test:            t,
+	}
+}
+
+// NewPlainCommandLineReport sets outputs to plain ASCII labels without any
+// color codes, for logs and terminals that do not support them.
+func NewPlainCommandLineReport(t *Test) *Report {
+	return &Report{
+		InfoLabel:       "[INFO]",
+		PassTestLabel:   "[PASS]",
+		FailTestLabel:   "[FAIL]",
+		SlowTestLabel:   "[SLOW]",
+		SkipTestLabel:   "[SKIP]",
+		TimingHeader:    "[   ms   ]",
+		FastThreshold:   100 * time.Millisecond,
+		SlowThreshold:   500 * time.Millisecond,
+		TimingBreakdown: true,
+		StatsTable:      true,
+		NoColor:         true,
+		Test:            t,
+	}
+}
+
+// NewCommandLineReport writes to w using the colored report when w is a
+// terminal, and the plain report otherwise or when the NO_COLOR environment
+// variable is set.
+func NewCommandLineReport(t *Test, w io.Writer) *Report {
+	var r *Report
+	if os.Getenv("NO_COLOR") == "" && isTerminal(w) {
+		r = NewColoredCommandLineReport(t)
+	} else {
+		r = NewPlainCommandLineReport(t)
+	}
+	r.Output = w
+	return r
+}
+
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return false
 	}
+
+	return info.Mode()&os.ModeCharDevice != 0
 }
 
-// PrintResults outputs report to stdout based on report fields.
+// AddReporter adds a reporter that writes to w each time PrintResults is
+// called, after the console report. For example, to also write JUnit XML:
+//
+//	r.AddReporter(f, irest.ReporterFunc(r.WriteJUnit))
+func (r *Report) AddReporter(w io.Writer, reporter Reporter) *Report {
+	r.reporters = append(r.reporters, reportOutput{w: w, reporter: reporter})
+	return r
+}
+
+// PrintResults outputs the console report to Output, or stdout if not set,
+// followed by any added reporters.
 func (r *Report) PrintResults() error {
+	w := r.Output
+	if w == nil {
+		w = os.Stdout
+	}
+
+	if err := r.WriteReport(w); err != nil {
+		return err
+	}
+
+	for _, out := range r.reporters {
+		if err := out.reporter.WriteReport(out.w); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteReport writes the console report to w based on report fields. Tests
+// are written depth first in the order they were added, with endpoint tests
+// listed under the test they belong to, followed by a summary.
+func (r *Report) WriteReport(w io.Writer) error {
 	if r.Test == nil {
 		return fmt.Errorf("Report.Test must be set")
 	}
 
-	fmt.Printf("%s %s %s\n", r.InfoLabel, r.TimingHeader, r.Test.Name)
+	root := newResult(r.Test, "", 0)
 
-	testStack := []*Test{}
-	testStack = append(testStack, r.Test.Tests...)
-	for len(testStack) > 0 {
-		next := testStack[len(testStack)-1]
-		r.printResult(next)
-		if next.Tests != nil || len(next.Tests) > 0 {
-			// Remove last test in stack.
-			testStack = append(testStack[:0], testStack[:len(testStack)-1]...)
+	info, header := r.label(r.InfoLabel), r.label(r.TimingHeader)
+	fmt.Fprintf(w, "%s %s %s\n", info, header, root.Name)
+	r.printChildren(w, root, "")
 
-			// Add all sub tests of next test.
-			testStack = append(testStack, next.Tests...)
-		}
+	summary := root.summary()
+	fmt.Fprintf(w, "%s %s %d passed, %d failed, %d skipped, %d slow in %s\n",
+		info, header, summary.Passed, summary.Failed, summary.Skipped, summary.Slow, summary.Duration)
+
+	if stats := endpointStats(root, r.Test.treeBudgets()); r.StatsTable && len(stats) > 0 {
+		fmt.Fprintln(w)
+		writeStatsTable(w, stats)
 	}
 
 	return nil
 }
 
-func (r *Report) printResult(t *Test) {
+// printChildren writes the children of a test with box-drawing branches,
+// where prefix holds the branches of the ancestors.
+func (r *Report) printChildren(w io.Writer, parent *result, prefix string) {
+	for i, c := range parent.Children {
+		branch, next := "├─ ", "│  "
+		if i == len(parent.Children)-1 {
+			branch, next = "└─ ", "   "
+		}
+
+		r.printResult(w, c, prefix+branch, prefix+next)
+		r.printChildren(w, c, prefix+next)
+	}
+}
+
+// thresholds returns the fast and slow timing thresholds.
+func (r *Report) thresholds() (time.Duration, time.Duration) {
+	fast, slow := r.FastThreshold, r.SlowThreshold
+	if fast == 0 {
+		fast = 100 * time.Millisecond
+	}
+	if slow == 0 {
+		slow = 500 * time.Millisecond
+	}
+	return fast, slow
+}
+
+// noColor reports whether the report is written without ANSI color codes,
+// because NoColor or the NO_COLOR environment variable is set.
+func (r *Report) noColor() bool {
+	return r.NoColor || os.Getenv("NO_COLOR") != ""
+}
+
+// color wraps s in the ANSI color code unless color is disabled.
+func (r *Report) color(code, s string) string {
+	if r.noColor() {
+		return s
+	}
+	return fmt.Sprintf("\033[%sm%s\033[0m", code, s)
+}
+
+// label returns a label, such as PassTestLabel, without its ANSI escape
+// sequences when color is disabled.
+func (r *Report) label(s string) string {
+	if !r.noColor() {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\033' && i+1 < len(s) && s[i+1] == '[' {
+			j := i + 2
+			for j < len(s) && (s[j] < '@' || s[j] > '~') {
+				j++
+			}
+			i = j
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func (r *Report) printResult(w io.Writer, t *result, branch, continuation string) {
+	if !t.requested() {
+		// Tests only grouping other tests have no request to show.
+		fmt.Fprintf(w, "%s [        ] %s%s\n", r.label(r.InfoLabel), branch, t.Name)
+		return
+	}
+
 	var timing string
 
-	// TODO: create thresholds that can be specified.
+	fast, slow := r.thresholds()
+	ms := fmt.Sprintf("%3d ms", t.Duration/time.Millisecond)
 	if t.Duration == 0 && t.Response == nil {
-		timing = "[      ]"
-	} else if t.Duration < 100 {
-		timing = fmt.Sprintf("[ \033[00;32m%3d ms\033[0m ]", t.Duration)
-	} else if t.Duration < 500 {
-		timing = fmt.Sprintf("[ \033[00;33m%3d ms\033[0m ]", t.Duration)
+		timing = "[        ]"
+	} else if t.Duration < fast {
+		timing = fmt.Sprintf("[ %s ]", r.color("00;32", ms))
+	} else if t.Duration < slow {
+		timing = fmt.Sprintf("[ %s ]", r.color("00;33", ms))
 	} else {
-		timing = fmt.Sprintf("[ \033[00;31m%3d ms\033[0m ]", t.Duration)
+		timing = fmt.Sprintf("[ %s ]", r.color("00;31", ms))
 	}
 
-	// Indents test by a separator to show groupings of tests.
-	indent := ""
-	for i := 0; i < t.Depth; i++ {
-		indent += "-"
+	// Load tests request many endpoints, so have none of their own to show.
+	request, endpoint := fmt.Sprintf("[%s] [%s] [%d] ", t.Method, t.Endpoint, t.Status), ""
+	if t.Endpoint != "" {
+		endpoint = " for " + t.Endpoint
+	} else if t.aggregate() && t.Method == "" {
+		request = ""
 	}
 
 	msg := t.Name
 	var result string
-	if t.Error == nil {
-		result = r.PassTestLabel
-	} else {
-		result = r.FailTestLabel
-		msg += fmt.Sprintf(" (%s) for %s", t.Error, t.Endpoint)
+	switch {
+	case t.Skipped:
+		result = r.label(r.SkipTestLabel)
+	case t.failed():
+		result = r.label(r.FailTestLabel)
+		msg += fmt.Sprintf(" (%s)%s", strings.Join(t.failures(), "; "), endpoint)
+	case t.slow():
+		// Over budget responses are flagged apart from functional failures.
+		result = r.label(r.SlowTestLabel)
+		msg += fmt.Sprintf(" (%s)%s", t.LatencyError, endpoint)
+	default:
+		result = r.label(r.PassTestLabel)
 	}
 
-	fmt.Printf("%s %s [%s] [%s] [%d] %s %s\n", result, timing, t.Method, t.Endpoint, t.Status, indent, msg)
+	fmt.Fprintf(w, "%s %s %s%s%s\n", result, timing, request, branch, msg)
+
+	if r.TimingBreakdown && t.Response != nil {
+		fmt.Fprintf(w, "%16s %s%s\n", "", continuation, t.Timing)
+	}
+	if r.TimingBreakdown && t.Compression != nil {
+		fmt.Fprintf(w, "%16s %s%s\n", "", continuation, t.Compression)
+	}
+
+	var b strings.Builder
+	if t.LoadResult != nil {
+		t.LoadResult.WriteTable(&b)
+	}
+	if t.RaceResult != nil {
+		t.RaceResult.WriteTable(&b)
+	}
+	if b.Len() > 0 {
+		for _, line := range strings.Split(strings.TrimRight(b.String(), "\n"), "\n") {
+			fmt.Fprintf(w, "%16s %s%s\n", "", continuation, line)
+		}
+	}
+}
+
+// redactedHeaders returns the headers to hide in requests and responses
+// shown in the report.
+func (r *Report) redactedHeaders() map[string]bool {
+	if r.RedactHeaders == nil {
+		return redactSet(DefaultRedactedHeaders)
+	}
+	return redactSet(r.RedactHeaders)
 }
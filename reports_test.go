@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"os"
 	"strings"
 	"testing"
 )
@@ -51,7 +50,12 @@ func TestReportOutputHasMethodStatusAndEndpoint(t *testing.T) {
 		`[POST] [/tests] [201]`,
 	}
 
-	output := captureStdout(report.PrintResults)
+	var b bytes.Buffer
+	report.Output = &b
+	if err := report.PrintResults(); err != nil {
+		t.Fatal(err)
+	}
+	output := b.String()
 
 	for _, expect := range expectedAdditionalOutput {
 		if !strings.Contains(output, expect) {
@@ -60,22 +64,167 @@ func TestReportOutputHasMethodStatusAndEndpoint(t *testing.T) {
 	}
 }
 
-func captureStdout(f func() error) string {
-	stdout := os.Stdout
-	r, w, _ := os.Pipe()
-	os.Stdout = w
-	f()
+func TestPlainCommandLineReport(t *testing.T) {
+	test := NewTest("unit-test")
+	test.NewTest("get").Get(api.URL, "/tests").MustStatus(200)
+	test.NewTest("failed get").Get(api.URL, "/tests").MustStatus(404)
+
+	var b bytes.Buffer
+	if err := NewPlainCommandLineReport(test).WriteReport(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	output := b.String()
+	if strings.Contains(output, "\033[") {
+		t.Error("expected no ANSI color codes in plain report:", output)
+	}
+
+	for _, expect := range []string{"[INFO]", "[PASS]", "[FAIL]"} {
+		if !strings.Contains(output, expect) {
+			t.Error("plain report output should contain:", expect)
+		}
+	}
+}
+
+func TestNewCommandLineReportNotTerminal(t *testing.T) {
+	var b bytes.Buffer
+	report := NewCommandLineReport(NewTest("unit-test"), &b)
+
+	if !report.NoColor || report.Output != &b {
+		t.Error("expected plain report writing to the buffer")
+	}
+
+	if err := report.PrintResults(); err != nil {
+		t.Fatal(err)
+	}
 
-	output := make(chan string)
-	go func() {
+	if !strings.HasPrefix(b.String(), "[INFO]") {
+		t.Error("expected plain report output, got:", b.String())
+	}
+}
+
+func TestReportNoColor(t *testing.T) {
+	test := NewTest("no color")
+	test.NewTest("pass").Get(api.URL, "/tests").MustStatus(http.StatusOK)
+	test.NewTest("fail").Get(api.URL, "/tests").MustStatus(http.StatusNotFound)
+	test.NewTest("skip").Skip().Get(api.URL, "/tests")
+	slow := test.NewTest("slow").Get(api.URL, "/tests")
+	slow.LatencyError = fmt.Errorf("expected under 1ms, actual %s", slow.Duration)
+	test.NewTest("group").NewTest("nested").Get(api.URL, "/tests")
+
+	write := func(r *Report) string {
 		var b bytes.Buffer
-		io.Copy(&b, r)
-		output <- b.String()
-	}()
+		if err := r.WriteReport(&b); err != nil {
+			t.Fatal(err)
+		}
+		return b.String()
+	}
 
-	w.Close()
-	os.Stdout = stdout
-	captured := <-output
+	if output := write(NewColoredCommandLineReport(test)); !strings.Contains(output, "\x1b[") {
+		t.Fatalf("expected color codes without NO_COLOR, actual %q", output)
+	}
 
-	return captured
+	report := NewColoredCommandLineReport(test)
+	report.NoColor = true
+	if output := write(report); strings.Contains(output, "\x1b[") {
+		t.Errorf("expected no color codes with NoColor, actual %q", output)
+	}
+
+	t.Setenv("NO_COLOR", "1")
+	output := write(NewColoredCommandLineReport(test))
+	if strings.Contains(output, "\x1b[") {
+		t.Errorf("expected no color codes with NO_COLOR, actual %q", output)
+	}
+	if !strings.Contains(output, "[ \u2713 ]") || !strings.Contains(output, "[ \u2718 ]") {
+		t.Errorf("expected the labels without their color, actual %q", output)
+	}
+}
+
+func TestAddReporter(t *testing.T) {
+	test := NewTest("unit-test")
+	test.NewTest("get").Get(api.URL, "/tests")
+
+	var console, junit, custom bytes.Buffer
+	report := NewPlainCommandLineReport(test)
+	report.Output = &console
+	report.AddReporter(&junit, ReporterFunc(report.WriteJUnit)).
+		AddReporter(&custom, ReporterFunc(func(w io.Writer) error {
+			_, err := io.WriteString(w, "custom")
+			return err
+		}))
+
+	if err := report.PrintResults(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(console.String(), "[PASS]") {
+		t.Error("expected console output, got:", console.String())
+	}
+
+	if !strings.Contains(junit.String(), "<testsuites") {
+		t.Error("expected junit output, got:", junit.String())
+	}
+
+	if custom.String() != "custom" {
+		t.Error("expected custom output, got:", custom.String())
+	}
+}
+
+func TestReportNoTest(t *testing.T) {
+	report := NewPlainCommandLineReport(nil)
+
+	if err := report.PrintResults(); err == nil {
+		t.Error("expected an error, but did not get one")
+	}
+}
+
+func TestReportTreeOrder(t *testing.T) {
+	test := NewTest("unit-test")
+
+	test.NewTest("first").Get(api.URL, "/tests")
+	group := test.NewTest("group")
+	group.NewTest("nested").Get(api.URL, "/tests")
+	group.NewTest("skipped").Skip().Get(api.URL, "/tests").MustStatus(404)
+
+	getEndpoint := &Endpoint{Path: "/tests", Method: http.MethodGet}
+	test.NewEndpointsTest("scenario",
+		getEndpoint.Use(api.URL, nil).Do().MustStatus(http.StatusOK),
+	)
+	test.NewTest("last").Get(api.URL, "/tests").MustStatus(404)
+
+	report := NewPlainCommandLineReport(test)
+	report.TimingBreakdown = false
+	report.StatsTable = false
+
+	var b bytes.Buffer
+	if err := report.WriteReport(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	expected := []string{
+		"unit-test",
+		"├─ first",
+		"├─ group",
+		"│  ├─ nested",
+		"│  └─ skipped",
+		"├─ scenario",
+		"│  └─ GET /tests",
+		"└─ last",
+		"3 passed, 1 failed, 1 skipped, 0 slow in",
+	}
+
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d lines, got %d: %s", len(expected), len(lines), b.String())
+	}
+
+	for i, expect := range expected {
+		if !strings.Contains(lines[i], expect) {
+			t.Errorf("expected line %d to contain '%s', got '%s'", i, expect, lines[i])
+		}
+	}
+
+	if !strings.HasPrefix(lines[4], "[SKIP]") || !strings.HasPrefix(lines[7], "[FAIL]") {
+		t.Error("expected skipped and failed labels:", b.String())
+	}
 }
@@ -0,0 +1,244 @@
+package irest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"time"
+)
+
+// result is a read-only view of a Test or EndpointTest so that reports can
+// render scenario steps the same way as any other test.
+type result struct {
+	Name     string
+	Path     string
+	Method   string
+	Endpoint string
+	Template string
+	Status   int
+	Created  time.Time
+	Duration time.Duration
+	Timing   Timing
+
+	Compression *Compression
+
+	Error        error
+	LatencyError error
+	Errors       []error
+	Skipped      bool
+
+	Request      *http.Request
+	RequestBody  []byte
+	Response     *http.Response
+	ResponseBody []byte
+
+	LoadResult *LoadResult
+	RaceResult *RaceResult
+
+	Depth    int
+	Children []*result
+}
+
+// newResult builds the result tree for a test. Endpoint tests are listed
+// before sub-tests, in the order they were added.
+func newResult(t *Test, parentPath string, depth int) *result {
+	r := &result{
+		Name:         t.Name,
+		Path:         joinPath(parentPath, t.Name),
+		Method:       t.Method,
+		Endpoint:     t.Endpoint,
+		Template:     t.Endpoint,
+		Status:       t.Status,
+		Created:      t.Created,
+		Duration:     t.Duration,
+		Timing:       t.Timing,
+		Compression:  t.Compression,
+		Error:        t.Error,
+		LatencyError: t.LatencyError,
+		Errors:       t.Errors,
+		Skipped:      t.Skipped,
+		Request:      t.request,
+		RequestBody:  t.requestBody,
+		Response:     t.Response,
+		ResponseBody: t.responseBody,
+		LoadResult:   t.LoadResult,
+		RaceResult:   t.RaceResult,
+		Depth:        depth,
+	}
+
+	for _, e := range t.EndpointTests {
+		r.Children = append(r.Children, newEndpointResult(e, r.Path, depth+1))
+	}
+
+	for _, sub := range t.Tests {
+		r.Children = append(r.Children, newResult(sub, r.Path, depth+1))
+	}
+
+	return r
+}
+
+func newEndpointResult(e *EndpointTest, parentPath string, depth int) *result {
+	name := e.Name
+	if name == "" {
+		name = fmt.Sprintf("%s %s", e.Method, e.Path)
+	}
+
+	endpoint := e.Path
+	if u, err := url.Parse(e.URL); err == nil && u.Path != "" {
+		endpoint = u.Path
+	}
+
+	status := 0
+	if e.Response != nil {
+		status = e.Response.StatusCode
+	}
+
+	return &result{
+		Name:         name,
+		Path:         joinPath(parentPath, name),
+		Method:       e.Method,
+		Endpoint:     endpoint,
+		Template:     e.Path,
+		Status:       status,
+		Duration:     e.Duration,
+		Timing:       e.Timing,
+		Compression:  e.Compression,
+		Error:        e.Error,
+		LatencyError: e.LatencyError,
+		Skipped:      e.Skipped,
+		Request:      e.Request,
+		RequestBody:  e.requestBody,
+		Response:     e.Response,
+		ResponseBody: e.responseBody,
+		Depth:        depth,
+	}
+}
+
+func joinPath(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "/" + name
+}
+
+// failed reports whether the test itself has a functional failure.
+func (r *result) failed() bool {
+	return !r.Skipped && (r.Error != nil || len(r.Errors) > 0)
+}
+
+// slow reports whether the test passed but was over its latency budget.
+func (r *result) slow() bool {
+	return !r.Skipped && !r.failed() && r.LatencyError != nil
+}
+
+// requested reports whether the test made, attempted or skipped a request, as
+// opposed to only grouping other tests.
+func (r *result) requested() bool {
+	return r.Request != nil || r.Response != nil || r.Method != "" || r.Endpoint != "" || r.Skipped || r.failed() || r.aggregate()
+}
+
+// aggregate reports whether the test holds the results of many requests, from
+// Load or RaceTest, rather than a single request.
+func (r *result) aggregate() bool {
+	return r.LoadResult != nil || r.RaceResult != nil
+}
+
+// summary counts the result and all of its children.
+func (r *result) summary() JSONSummary {
+	var s JSONSummary
+	r.walk(func(c *result) {
+		if !c.requested() {
+			return
+		}
+
+		s.Tests++
+		s.Duration += c.Duration
+		switch {
+		case c.Skipped:
+			s.Skipped++
+		case c.failed():
+			s.Failed++
+		case c.slow():
+			s.Slow++
+		default:
+			s.Passed++
+		}
+	})
+	return s
+}
+
+// failures returns the messages of Error followed by Errors. Skipped tests
+// have no failures.
+func (r *result) failures() []string {
+	if r.Skipped {
+		return nil
+	}
+
+	var msgs []string
+	if r.Error != nil {
+		msgs = append(msgs, r.Error.Error())
+	}
+	for _, err := range r.Errors {
+		msgs = append(msgs, err.Error())
+	}
+	return msgs
+}
+
+// walk calls fn for the result and its children depth first in order.
+func (r *result) walk(fn func(*result)) {
+	fn(r)
+	for _, c := range r.Children {
+		c.walk(fn)
+	}
+}
+
+// requests returns the tests that made requests, depth first in order.
+func (r *result) requests() []*result {
+	var requests []*result
+	r.walk(func(c *result) {
+		if c.requested() {
+			requests = append(requests, c)
+		}
+	})
+	return requests
+}
+
+// redactSet returns the canonical names of the headers to redact.
+func redactSet(headers []string) map[string]bool {
+	redact := map[string]bool{}
+	for _, h := range headers {
+		redact[http.CanonicalHeaderKey(h)] = true
+	}
+	return redact
+}
+
+// dumpRequest returns the raw request with its body, if one was made, with
+// the values of the redacted headers hidden.
+func (r *result) dumpRequest(redact map[string]bool) string {
+	if r.Request == nil {
+		return ""
+	}
+
+	dump, err := httputil.DumpRequest(r.Request, false)
+	if err != nil {
+		return err.Error()
+	}
+
+	return redactDump(append(dump, r.RequestBody...), redact)
+}
+
+// dumpResponse returns the raw response with the body as it was received,
+// with the values of the redacted headers hidden.
+func (r *result) dumpResponse(redact map[string]bool) string {
+	if r.Response == nil {
+		return ""
+	}
+
+	dump, err := httputil.DumpResponse(r.Response, false)
+	if err != nil {
+		return err.Error()
+	}
+
+	return redactDump(append(dump, r.ResponseBody...), redact)
+}
@@ -0,0 +1,296 @@
+package irest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Signer signs requests once they are built, just before they are sent.
+// Middleware runs after signing, so it must not change signed parts of the
+// request.
+type Signer interface {
+	Sign(req *http.Request, body []byte) error
+}
+
+// Sign signs the requests of the test and of sub-tests created after it.
+func (t *Test) Sign(s Signer) *Test {
+	t.Signer = s
+	return t
+}
+
+// Sign signs the request of the endpoint test.
+func (e *EndpointTest) Sign(s Signer) *EndpointTest {
+	e.Signer = s
+	return e
+}
+
+// HMACSigner signs requests with an HMAC of a canonical string built from
+// parts of the request.
+type HMACSigner struct {
+	Key []byte
+
+	// Hash is the hash function of the HMAC, SHA-256 when nil.
+	Hash func() hash.Hash
+
+	// Components are the request parts joined by newlines to make the
+	// canonical string: "method", "path", "query", "host", "date",
+	// "body-sha256" or "header:<name>". DefaultHMACComponents when nil.
+	Components []string
+
+	// Header is set to the signature, X-Signature when empty.
+	Header string
+
+	// DateHeader is set to the time of signing, in HTTP date format, when
+	// the request does not have one. Date when empty.
+	DateHeader string
+
+	// Base64 encodes the signature in base64 instead of hex.
+	Base64 bool
+
+	// Now returns the time of signing, time.Now when nil.
+	Now func() time.Time
+}
+
+// DefaultHMACComponents are the parts of the request signed by an HMACSigner
+// unless others are set.
+var DefaultHMACComponents = []string{"method", "path", "date", "body-sha256"}
+
+// CanonicalString returns the string the signature is computed over. The
+// date header must already be set.
+func (s *HMACSigner) CanonicalString(req *http.Request, body []byte) (string, error) {
+	components := s.Components
+	if components == nil {
+		components = DefaultHMACComponents
+	}
+
+	parts := make([]string, 0, len(components))
+	for _, c := range components {
+		switch {
+		case c == "method":
+			parts = append(parts, req.Method)
+		case c == "path":
+			parts = append(parts, req.URL.EscapedPath())
+		case c == "query":
+			parts = append(parts, req.URL.Query().Encode())
+		case c == "host":
+			parts = append(parts, requestHost(req))
+		case c == "date":
+			parts = append(parts, req.Header.Get(s.dateHeader()))
+		case c == "body-sha256":
+			sum := sha256.Sum256(body)
+			parts = append(parts, hex.EncodeToString(sum[:]))
+		case strings.HasPrefix(c, "header:"):
+			parts = append(parts, strings.TrimSpace(req.Header.Get(c[len("header:"):])))
+		default:
+			return "", fmt.Errorf("unknown HMAC component %s", c)
+		}
+	}
+
+	return strings.Join(parts, "\n"), nil
+}
+
+func (s *HMACSigner) dateHeader() string {
+	if s.DateHeader == "" {
+		return "Date"
+	}
+	return s.DateHeader
+}
+
+// Sign sets the date header if needed and the signature header.
+func (s *HMACSigner) Sign(req *http.Request, body []byte) error {
+	if req.Header.Get(s.dateHeader()) == "" {
+		now := time.Now
+		if s.Now != nil {
+			now = s.Now
+		}
+		req.Header.Set(s.dateHeader(), now().UTC().Format(http.TimeFormat))
+	}
+
+	canonical, err := s.CanonicalString(req, body)
+	if err != nil {
+		return err
+	}
+
+	h := s.Hash
+	if h == nil {
+		h = sha256.New
+	}
+	mac := hmac.New(h, s.Key)
+	mac.Write([]byte(canonical))
+	sum := mac.Sum(nil)
+
+	signature := hex.EncodeToString(sum)
+	if s.Base64 {
+		signature = base64.StdEncoding.EncodeToString(sum)
+	}
+
+	header := s.Header
+	if header == "" {
+		header = "X-Signature"
+	}
+	req.Header.Set(header, signature)
+
+	return nil
+}
+
+// requestHost returns the host the request is sent to.
+func requestHost(req *http.Request) string {
+	if req.Host != "" {
+		return req.Host
+	}
+	return req.URL.Host
+}
+
+// SigV4Signer signs requests with AWS Signature Version 4.
+type SigV4Signer struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Region          string
+
+	// Service is the signing name of the service. Paths are encoded twice
+	// for signing, except for s3, which also signs the payload hash header.
+	Service string
+
+	// Now returns the time of signing, time.Now when nil.
+	Now func() time.Time
+}
+
+// sigV4Unsigned are headers left out of the signature as proxies and
+// clients may change them.
+var sigV4Unsigned = map[string]bool{
+	"authorization":   true,
+	"user-agent":      true,
+	"expect":          true,
+	"x-amzn-trace-id": true,
+	"content-length":  true,
+}
+
+// Sign sets the X-Amz-Date, security token and Authorization headers.
+func (s *SigV4Signer) Sign(req *http.Request, body []byte) error {
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+	t := now().UTC()
+	amzDate := t.Format("20060102T150405Z")
+	date := t.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+
+	payloadSum := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(payloadSum[:])
+	if s.Service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	canonical, signedHeaders := s.canonicalRequest(req, payloadHash)
+	scope := strings.Join([]string{date, s.Region, s.Service, "aws4_request"}, "/")
+	canonicalSum := sha256.Sum256([]byte(canonical))
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hex.EncodeToString(canonicalSum[:])}, "\n")
+
+	key := []byte("AWS4" + s.SecretAccessKey)
+	for _, part := range []string{date, s.Region, s.Service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKeyID, scope, signedHeaders, signature))
+
+	return nil
+}
+
+// canonicalRequest returns the canonical request and the signed header
+// names of a request.
+func (s *SigV4Signer) canonicalRequest(req *http.Request, payloadHash string) (string, string) {
+	headers := map[string]string{"host": requestHost(req)}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if sigV4Unsigned[name] {
+			continue
+		}
+		trimmed := make([]string, len(values))
+		for i, v := range values {
+			trimmed[i] = strings.Join(strings.Fields(v), " ")
+		}
+		headers[name] = strings.Join(trimmed, ",")
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", name, headers[name])
+	}
+
+	// The canonical URI encodes the path as sent, so segments already
+	// percent-encoded in the request are encoded twice, except for S3.
+	path := req.URL.EscapedPath()
+	if s.Service == "s3" {
+		path = req.URL.Path
+	}
+	if path == "" {
+		path = "/"
+	}
+
+	return strings.Join([]string{
+		req.Method,
+		sigV4Escape(path, false),
+		sigV4Query(req.URL.Query()),
+		canonicalHeaders.String(),
+		strings.Join(names, ";"),
+		payloadHash,
+	}, "\n"), strings.Join(names, ";")
+}
+
+// sigV4Query returns the query parameters sorted and escaped for signing.
+func sigV4Query(query url.Values) string {
+	pairs := []string{}
+	for name, values := range query {
+		for _, v := range values {
+			pairs = append(pairs, sigV4Escape(name, true)+"="+sigV4Escape(v, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// sigV4Escape percent-encodes all but the unreserved characters, and the
+// slashes of a path.
+func sigV4Escape(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
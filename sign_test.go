@@ -0,0 +1,211 @@
+package irest
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// signingTime is the time used by the AWS Signature Version 4 test suite.
+var signingTime = func() time.Time { return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC) }
+
+func TestSigV4Vectors(t *testing.T) {
+	var vectorTests = []struct {
+		name    string
+		method  string
+		url     string
+		service string
+		header  map[string]string
+		auth    string
+	}{
+		{
+			"get-vanilla", http.MethodGet, "https://example.amazonaws.com/", "service", nil,
+			"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			"get-vanilla-query-order-key-case", http.MethodGet, "https://example.amazonaws.com/?Param2=value2&Param1=value1", "service", nil,
+			"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+		},
+		{
+			"post-vanilla", http.MethodPost, "https://example.amazonaws.com/", "service", nil,
+			"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5da7c1a2acd57cee7505fc6676e4e544621c30862966e37dddb68e92efbe5d6b",
+		},
+		{
+			// The suite's get-space request, with the path encoded twice
+			// as services other than S3 expect.
+			"get-space", http.MethodGet, "https://example.amazonaws.com/example space/", "service", nil,
+			"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=446b817944c553435b35e813c261ff4e161fff982d1bacdef1c87f6785dd1662",
+		},
+		{
+			"iam-list-users", http.MethodGet, "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", "iam",
+			map[string]string{"Content-Type": "application/x-www-form-urlencoded; charset=utf-8"},
+			"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, SignedHeaders=content-type;host;x-amz-date, Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7",
+		},
+	}
+
+	for _, tt := range vectorTests {
+		req, err := http.NewRequest(tt.method, tt.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, value := range tt.header {
+			req.Header.Set(name, value)
+		}
+
+		signer := &SigV4Signer{
+			AccessKeyID:     "AKIDEXAMPLE",
+			SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+			Region:          "us-east-1",
+			Service:         tt.service,
+			Now:             signingTime,
+		}
+		if err := signer.Sign(req, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		if actual := req.Header.Get("Authorization"); actual != tt.auth {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.auth, actual)
+		}
+
+		if actual := req.Header.Get("X-Amz-Date"); actual != "20150830T123600Z" {
+			t.Errorf("%s: expected X-Amz-Date 20150830T123600Z, got %s", tt.name, actual)
+		}
+	}
+}
+
+func TestSigV4SessionToken(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPut, "https://bucket.s3.amazonaws.com/a b.txt", nil)
+	signer := &SigV4Signer{AccessKeyID: "AK", SecretAccessKey: "SK", SessionToken: "session", Region: "eu-west-1", Service: "s3", Now: signingTime}
+	if err := signer.Sign(req, []byte("body")); err != nil {
+		t.Fatal(err)
+	}
+
+	if req.Header.Get("X-Amz-Security-Token") != "session" || req.Header.Get("X-Amz-Content-Sha256") == "" {
+		t.Errorf("expected session token and payload hash headers, got %v", req.Header)
+	}
+
+	if auth := req.Header.Get("Authorization"); !strings.Contains(auth, "SignedHeaders=host;x-amz-content-sha256;x-amz-date;x-amz-security-token,") {
+		t.Errorf("expected the added headers to be signed, got %s", auth)
+	}
+
+	canonical, _ := signer.canonicalRequest(req, "")
+	if !strings.HasPrefix(canonical, "PUT\n/a%20b.txt\n") {
+		t.Errorf("expected the path to be escaped, got %q", canonical)
+	}
+}
+
+func TestSigV4CanonicalURI(t *testing.T) {
+	var uriTests = []struct {
+		service  string
+		path     string
+		expected string
+	}{
+		{"service", "/example space/", "/example%2520space/"},
+		{"service", "/documents and settings/", "/documents%2520and%2520settings/"},
+		{"service", "/a%2Fb", "/a%252Fb"},
+		{"s3", "/example space/", "/example%20space/"},
+		{"service", "", "/"},
+	}
+
+	for _, tt := range uriTests {
+		req, _ := http.NewRequest(http.MethodGet, "https://example.amazonaws.com"+tt.path, nil)
+		signer := &SigV4Signer{Service: tt.service}
+		canonical, _ := signer.canonicalRequest(req, "")
+		if actual := strings.Split(canonical, "\n")[1]; actual != tt.expected {
+			t.Errorf("%s %q: expected canonical URI %s, actual %s", tt.service, tt.path, tt.expected, actual)
+		}
+	}
+}
+
+func TestHMACVectors(t *testing.T) {
+	var hmacTests = []struct {
+		signer   *HMACSigner
+		expected string
+	}{
+		// RFC 4231 test case 2.
+		{&HMACSigner{Key: []byte("Jefe"), Components: []string{"header:X-Data"}}, "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"},
+		{&HMACSigner{Key: []byte("Jefe"), Components: []string{"header:X-Data"}, Base64: true}, "W9zBRr9gdU5qBCQmCJV1x1oAPwidJzmDnexYuWTsOEM="},
+		{&HMACSigner{Key: []byte("key"), Components: []string{"header:X-Data"}, Hash: sha1.New}, "554a18e946a2b3af7f64b561652eb7acc91496d6"},
+	}
+
+	for _, tt := range hmacTests {
+		req, _ := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+		req.Header.Set("X-Data", "what do ya want for nothing?")
+		if err := tt.signer.Sign(req, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		if actual := req.Header.Get("X-Signature"); actual != tt.expected {
+			t.Errorf("expected %s, got %s", tt.expected, actual)
+		}
+	}
+}
+
+func TestHMACDefaultComponents(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, "https://example.com/orders?page=1", nil)
+	signer := &HMACSigner{Key: []byte("secret"), Now: signingTime}
+	body := []byte("{\"amount\":10}\n")
+	if err := signer.Sign(req, body); err != nil {
+		t.Fatal(err)
+	}
+
+	canonical, err := signer.CanonicalString(req, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "POST\n/orders\nSun, 30 Aug 2015 12:36:00 GMT\neee2e817090bb019db9cfeaf208627862f1666d354c1aa680a6cc28be45d87fc"
+	if canonical != expected {
+		t.Errorf("expected canonical string %q, got %q", expected, canonical)
+	}
+
+	if actual := req.Header.Get("X-Signature"); actual != "610e2d44b8f8ae187368e2529eb7354f58f81a0eb6c220a77f561453158f1169" {
+		t.Errorf("unexpected signature %s", actual)
+	}
+
+	signer.Components = []string{"method", "unknown"}
+	if _, err := signer.CanonicalString(req, body); err == nil {
+		t.Error("expected an error for an unknown component")
+	}
+}
+
+// apiSigner is the signer checkSignature checks requests against.
+var apiSigner = &HMACSigner{Key: []byte("secret")}
+
+// checkSignature accepts requests whose HMAC signature matches.
+func checkSignature(w http.ResponseWriter, r *http.Request) {
+	body := make([]byte, r.ContentLength)
+	r.Body.Read(body)
+
+	expected := r.Clone(r.Context())
+	expected.Header = r.Header.Clone()
+	apiSigner.Sign(expected, body)
+
+	if r.Header.Get("X-Signature") == "" || r.Header.Get("X-Signature") != expected.Header.Get("X-Signature") {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	fmt.Fprint(w, "{}")
+}
+
+func TestSignRequests(t *testing.T) {
+	test := NewTest("signed").Sign(apiSigner)
+	child := test.NewTest("child").Post(api.URL, "/signed/orders", map[string]int{"amount": 10}).MustStatus(http.StatusOK)
+	if child.Error != nil {
+		t.Fatal(child.Error)
+	}
+
+	create := &Endpoint{Path: "/signed/orders", Method: http.MethodPost}
+	e := create.Use(api.URL, map[string]int{"amount": 10}).In(test).Do().MustStatus(http.StatusOK)
+	if e.Error != nil {
+		t.Fatal(e.Error)
+	}
+
+	unsigned := NewTest("unsigned").Get(api.URL, "/signed/orders").MustStatus(http.StatusUnauthorized)
+	if unsigned.Error != nil {
+		t.Fatal(unsigned.Error)
+	}
+}
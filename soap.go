@@ -0,0 +1,291 @@
+package irest
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// SOAPVersion is the version of a SOAP envelope.
+type SOAPVersion int
+
+const (
+	// SOAP11 envelopes are sent as text/xml with a SOAPAction header.
+	SOAP11 SOAPVersion = iota
+	// SOAP12 envelopes are sent as application/soap+xml with the action as
+	// a content type parameter.
+	SOAP12
+)
+
+// namespace returns the envelope namespace of the version.
+func (v SOAPVersion) namespace() string {
+	if v == SOAP12 {
+		return "http://www.w3.org/2003/05/soap-envelope"
+	}
+	return "http://schemas.xmlsoap.org/soap/envelope/"
+}
+
+// SOAPEnvelope is a SOAP request payload. Header and Body are encoded as XML,
+// except []byte and string values, which are written as they are.
+type SOAPEnvelope struct {
+	Version SOAPVersion
+	Action  string
+	Header  interface{}
+	Body    interface{}
+}
+
+// NewSOAPEnvelope creates a SOAP 1.1 envelope for the action with the body.
+func NewSOAPEnvelope(action string, body interface{}) *SOAPEnvelope {
+	return &SOAPEnvelope{Version: SOAP11, Action: action, Body: body}
+}
+
+// soapElement encodes the content of a header or body element.
+func soapElement(v interface{}) ([]byte, error) {
+	switch c := v.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		return c, nil
+	case string:
+		return []byte(c), nil
+	default:
+		return xml.Marshal(v)
+	}
+}
+
+// EncodeBody writes the envelope with its header, when set, and body.
+func (s *SOAPEnvelope) EncodeBody() (string, []byte, error) {
+	header, err := soapElement(s.Header)
+	if err != nil {
+		return "", nil, err
+	}
+	body, err := soapElement(s.Body)
+	if err != nil {
+		return "", nil, err
+	}
+
+	b := new(bytes.Buffer)
+	b.WriteString(xml.Header)
+	fmt.Fprintf(b, `<soap:Envelope xmlns:soap="%s">`, s.Version.namespace())
+	if header != nil {
+		fmt.Fprintf(b, "<soap:Header>%s</soap:Header>", header)
+	}
+	fmt.Fprintf(b, "<soap:Body>%s</soap:Body></soap:Envelope>", body)
+
+	if s.Version == SOAP12 {
+		params := map[string]string{"charset": "utf-8"}
+		if s.Action != "" {
+			params["action"] = s.Action
+		}
+		return mime.FormatMediaType("application/soap+xml", params), b.Bytes(), nil
+	}
+	return "text/xml; charset=utf-8", b.Bytes(), nil
+}
+
+// BodyHeader returns the SOAPAction header of SOAP 1.1 envelopes.
+func (s *SOAPEnvelope) BodyHeader() http.Header {
+	if s.Version == SOAP12 {
+		return nil
+	}
+	return http.Header{"Soapaction": {`"` + s.Action + `"`}}
+}
+
+// SOAPFault is a fault returned in a SOAP response body, of either version.
+// Code is the fault code without its namespace prefix, such as Server or
+// Receiver.
+type SOAPFault struct {
+	Code   string
+	Reason string
+	Actor  string
+	Detail string
+}
+
+// Error returns the code and reason of the fault.
+func (f *SOAPFault) Error() string {
+	return fmt.Sprintf("SOAP fault %s: %s", f.Code, f.Reason)
+}
+
+// child returns the first child element with the local name.
+func (n *xmlNode) child(name string) *xmlNode {
+	if n == nil {
+		return nil
+	}
+	for _, c := range n.children {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// textValue returns the string value of the node, or "" for a missing node.
+func (n *xmlNode) textValue() string {
+	if n == nil {
+		return ""
+	}
+	return n.value()
+}
+
+// soapFault returns the fault in a SOAP response body, or nil if it has none.
+func soapFault(body []byte) (*SOAPFault, error) {
+	doc, err := parseXML(body)
+	if err != nil {
+		return nil, err
+	}
+
+	fault := doc.child("Envelope").child("Body").child("Fault")
+	if fault == nil {
+		return nil, nil
+	}
+
+	f := &SOAPFault{}
+	if fault.child("faultcode") != nil {
+		f.Code = fault.child("faultcode").textValue()
+		f.Reason = fault.child("faultstring").textValue()
+		f.Actor = fault.child("faultactor").textValue()
+		f.Detail = fault.child("detail").textValue()
+	} else {
+		f.Code = fault.child("Code").child("Value").textValue()
+		f.Reason = fault.child("Reason").child("Text").textValue()
+		f.Actor = fault.child("Role").textValue()
+		f.Detail = fault.child("Detail").textValue()
+	}
+	if i := strings.LastIndex(f.Code, ":"); i >= 0 {
+		f.Code = f.Code[i+1:]
+	}
+
+	return f, nil
+}
+
+// parseSOAPBody decodes the first element in the body of a SOAP envelope
+// into result. A fault is returned as a *SOAPFault error.
+func parseSOAPBody(body []byte, result interface{}) error {
+	fault, err := soapFault(body)
+	if err != nil {
+		return err
+	}
+	if fault != nil {
+		return fault
+	}
+
+	d := xml.NewDecoder(bytes.NewReader(body))
+	inBody := false
+	for {
+		token, err := d.Token()
+		if err == io.EOF {
+			return fmt.Errorf("expected a SOAP envelope with a Body")
+		}
+		if err != nil {
+			return fmt.Errorf("invalid XML: %s", err)
+		}
+
+		switch tok := token.(type) {
+		case xml.StartElement:
+			if !inBody {
+				inBody = tok.Name.Local == "Body"
+				continue
+			}
+			return d.DecodeElement(result, &tok)
+		case xml.EndElement:
+			if inBody {
+				return nil
+			}
+		}
+	}
+}
+
+func checkSOAPFault(body []byte, code, reason Matcher) error {
+	fault, err := soapFault(body)
+	if err != nil {
+		return err
+	}
+	if fault == nil {
+		return fmt.Errorf("expected a SOAP fault, actual none")
+	}
+
+	if code != nil {
+		if err := code(fault.Code); err != nil {
+			return fmt.Errorf("SOAP fault code: %s", err)
+		}
+	}
+	if reason != nil {
+		if err := reason(fault.Reason); err != nil {
+			return fmt.Errorf("SOAP fault reason: %s", err)
+		}
+	}
+	return nil
+}
+
+// ParseSOAPBody decodes the content of the SOAP response body into result.
+// If the response is a fault, the Test.Error is set to its *SOAPFault.
+func (t *Test) ParseSOAPBody(result interface{}) *Test {
+	if t.Error != nil {
+		return t
+	}
+
+	if t.Response == nil {
+		t.Error = errNoResponse
+		return t
+	}
+
+	t.Error = parseSOAPBody(t.responseBody, result)
+
+	return t
+}
+
+// MustSOAPFault sets the Test.Error if the SOAP response body is not a fault
+// or its code or reason fails the matcher. A nil matcher is not checked.
+func (t *Test) MustSOAPFault(code, reason Matcher) *Test {
+	if t.Error != nil {
+		return t
+	}
+
+	if t.Response == nil {
+		t.Error = errNoResponse
+		return t
+	}
+
+	t.Error = checkSOAPFault(t.responseBody, code, reason)
+
+	return t
+}
+
+// ParseSOAPBody decodes the content of the SOAP response body into result.
+// If the response is a fault, the EndpointTest.Error is set to its
+// *SOAPFault.
+func (e *EndpointTest) ParseSOAPBody(result interface{}) *EndpointTest {
+	if e.Error != nil {
+		return e
+	}
+
+	if e.Response == nil {
+		e.Error = errNoResponse
+		return e
+	}
+
+	e.Error = parseSOAPBody(e.responseBody, result)
+
+	return e
+}
+
+// MustSOAPFault sets the EndpointTest.Error if the SOAP response body is not
+// a fault or its code or reason fails the matcher. A nil matcher is not
+// checked.
+func (e *EndpointTest) MustSOAPFault(code, reason Matcher) *EndpointTest {
+	if e.Error != nil {
+		return e
+	}
+
+	if e.Response == nil {
+		e.Error = errNoResponse
+		return e
+	}
+
+	e.Error = checkSOAPFault(e.responseBody, code, reason)
+
+	return e
+}
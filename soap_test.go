@@ -0,0 +1,164 @@
+package irest
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type getPrice struct {
+	XMLName xml.Name `xml:"urn:shop GetPrice"`
+	Item    string   `xml:"Item"`
+}
+
+type getPriceResponse struct {
+	Price float64 `xml:"Price"`
+}
+
+const soap11Fault = `<?xml version="1.0"?>
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap:Body>
+    <soap:Fault>
+      <faultcode>soap:Client</faultcode>
+      <faultstring>Unknown item</faultstring>
+      <detail><item>socks</item></detail>
+    </soap:Fault>
+  </soap:Body>
+</soap:Envelope>`
+
+const soap12Fault = `<?xml version="1.0"?>
+<env:Envelope xmlns:env="http://www.w3.org/2003/05/soap-envelope">
+  <env:Body>
+    <env:Fault>
+      <env:Code><env:Value>env:Receiver</env:Value></env:Code>
+      <env:Reason><env:Text xml:lang="en">Out of stock</env:Text></env:Reason>
+      <env:Role>urn:warehouse</env:Role>
+    </env:Fault>
+  </env:Body>
+</env:Envelope>`
+
+// newShopAPI serves GetPrice SOAP requests, with a fault for unknown items,
+// and records the request.
+func newShopAPI(req **http.Request, body *[]byte) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*req = r
+		*body, _ = ioutil.ReadAll(r.Body)
+
+		w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+		if !strings.Contains(string(*body), "<Item>hat</Item>") {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(soap11Fault))
+			return
+		}
+		w.Write([]byte(`<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap:Body><GetPriceResponse xmlns="urn:shop"><Price>12.5</Price></GetPriceResponse></soap:Body>
+</soap:Envelope>`))
+	})
+}
+
+func TestSOAPEnvelope(t *testing.T) {
+	var req *http.Request
+	var body []byte
+
+	var result getPriceResponse
+	test := NewTest("soap").Handler(newShopAPI(&req, &body)).
+		Post("", "/shop", NewSOAPEnvelope("urn:shop#GetPrice", getPrice{Item: "hat"})).
+		MustStatus(http.StatusOK).
+		ParseSOAPBody(&result)
+	if test.Error != nil {
+		t.Fatal(test.Error)
+	}
+	if result.Price != 12.5 {
+		t.Errorf("expected price 12.5, got %v", result.Price)
+	}
+
+	if actual := req.Header.Get("Content-Type"); actual != "text/xml; charset=utf-8" {
+		t.Errorf("expected SOAP 1.1 content type, got %s", actual)
+	}
+	if actual := req.Header.Get("SOAPAction"); actual != `"urn:shop#GetPrice"` {
+		t.Errorf("expected quoted SOAPAction, got %s", actual)
+	}
+
+	expected := `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body><GetPrice xmlns="urn:shop"><Item>hat</Item></GetPrice></soap:Body></soap:Envelope>`
+	if !strings.HasSuffix(string(body), expected) {
+		t.Errorf("expected envelope %s, got %s", expected, body)
+	}
+}
+
+func TestSOAP12Envelope(t *testing.T) {
+	envelope := &SOAPEnvelope{Version: SOAP12, Action: "urn:shop#GetPrice", Header: "<Token>abc</Token>", Body: getPrice{Item: "hat"}}
+
+	contentType, body, err := envelope.EncodeBody()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contentType != `application/soap+xml; action="urn:shop#GetPrice"; charset=utf-8` {
+		t.Errorf("expected SOAP 1.2 content type with the action, got %s", contentType)
+	}
+	if envelope.BodyHeader() != nil {
+		t.Error("expected no SOAPAction header for SOAP 1.2")
+	}
+	if !strings.Contains(string(body), `<soap:Envelope xmlns:soap="http://www.w3.org/2003/05/soap-envelope"><soap:Header><Token>abc</Token></soap:Header>`) {
+		t.Errorf("expected SOAP 1.2 envelope with header, got %s", body)
+	}
+}
+
+func TestSOAPFault(t *testing.T) {
+	var faultTests = []struct {
+		name     string
+		body     string
+		expected SOAPFault
+	}{
+		{"1.1", soap11Fault, SOAPFault{Code: "Client", Reason: "Unknown item", Detail: "socks"}},
+		{"1.2", soap12Fault, SOAPFault{Code: "Receiver", Reason: "Out of stock", Actor: "urn:warehouse"}},
+	}
+
+	for _, tt := range faultTests {
+		fault, err := soapFault([]byte(tt.body))
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		if fault == nil || *fault != tt.expected {
+			t.Errorf("%s: expected fault %+v, got %+v", tt.name, tt.expected, fault)
+		}
+	}
+}
+
+func TestMustSOAPFault(t *testing.T) {
+	var req *http.Request
+	var body []byte
+	handler := newShopAPI(&req, &body)
+
+	test := NewTest("soap fault").Handler(handler).
+		Post("", "/shop", NewSOAPEnvelope("urn:shop#GetPrice", getPrice{Item: "socks"})).
+		MustStatus(http.StatusInternalServerError).
+		MustSOAPFault(Equals("Client"), Contains("Unknown"))
+	if test.Error != nil {
+		t.Fatal(test.Error)
+	}
+
+	var result getPriceResponse
+	test = NewTest("soap parse fault").Handler(handler).
+		Post("", "/shop", NewSOAPEnvelope("urn:shop#GetPrice", getPrice{Item: "socks"})).
+		ParseSOAPBody(&result)
+	if fault, ok := test.Error.(*SOAPFault); !ok || fault.Reason != "Unknown item" {
+		t.Errorf("expected the SOAP fault as error, got %v", test.Error)
+	}
+
+	test = NewTest("soap no fault").Handler(handler).
+		Post("", "/shop", NewSOAPEnvelope("urn:shop#GetPrice", getPrice{Item: "hat"})).
+		MustSOAPFault(nil, nil)
+	if test.Error == nil {
+		t.Error("expected an error for a response without a fault")
+	}
+
+	test = NewTest("soap fault code").Handler(handler).
+		Post("", "/shop", NewSOAPEnvelope("urn:shop#GetPrice", getPrice{Item: "socks"})).
+		MustSOAPFault(Equals("Server"), nil)
+	if test.Error == nil {
+		t.Error("expected an error for the wrong fault code")
+	}
+}
@@ -0,0 +1,416 @@
+package irest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event is a Server-Sent Event received from a text/event-stream response.
+type Event struct {
+	// ID is the last event ID set by the stream when the event was received.
+	ID string `json:"id,omitempty"`
+
+	// Type is the event field, or message when it was not set.
+	Type string `json:"type"`
+
+	// Data is the data fields of the event joined by newlines.
+	Data string `json:"data"`
+}
+
+// EventCondition reports whether enough events have been received to stop
+// reading the stream.
+type EventCondition func(events []Event) bool
+
+// UntilCount stops reading the stream after n events.
+func UntilCount(n int) EventCondition {
+	return func(events []Event) bool {
+		return len(events) >= n
+	}
+}
+
+// UntilType stops reading the stream after an event of the type.
+func UntilType(eventType string) EventCondition {
+	return func(events []Event) bool {
+		return len(events) > 0 && events[len(events)-1].Type == eventType
+	}
+}
+
+// eventReader parses an event stream as the HTML Living Standard describes.
+type eventReader struct {
+	r   *bufio.Reader
+	raw bytes.Buffer
+
+	lastEventID string
+	retry       time.Duration
+}
+
+// readLine reads a line ended by CRLF, LF or CR, without its end.
+func (er *eventReader) readLine() (string, error) {
+	var line []byte
+	for {
+		c, err := er.r.ReadByte()
+		if err != nil {
+			if err == io.EOF && len(line) > 0 {
+				return string(line), nil
+			}
+			return "", err
+		}
+		er.raw.WriteByte(c)
+
+		switch c {
+		case '\n':
+			return string(line), nil
+		case '\r':
+			if next, err := er.r.Peek(1); err == nil && next[0] == '\n' {
+				er.r.ReadByte()
+				er.raw.WriteByte('\n')
+			}
+			return string(line), nil
+		}
+		line = append(line, c)
+	}
+}
+
+// next returns the next event dispatched by the stream.
+func (er *eventReader) next() (Event, error) {
+	var eventType string
+	var data []string
+
+	for {
+		line, err := er.readLine()
+		if err != nil {
+			return Event{}, err
+		}
+		line = strings.TrimPrefix(line, "\ufeff")
+
+		if line == "" {
+			if data == nil {
+				eventType = ""
+				continue
+			}
+			if eventType == "" {
+				eventType = "message"
+			}
+			return Event{ID: er.lastEventID, Type: eventType, Data: strings.Join(data, "\n")}, nil
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value := line, ""
+		if i := strings.Index(line, ":"); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+
+		switch field {
+		case "event":
+			eventType = value
+		case "data":
+			data = append(data, value)
+		case "id":
+			if !strings.Contains(value, "\x00") {
+				er.lastEventID = value
+			}
+		case "retry":
+			if ms, err := strconv.ParseUint(value, 10, 64); err == nil {
+				er.retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+}
+
+// Stream opens a Server-Sent Events stream with a GET request to the URL
+// from baseURL combined with endpoint and collects its events in
+// Test.Events until the condition is met, the stream ends or the timeout
+// passes. A timeout before a non-nil condition is met sets the Test.Error.
+// With Handler, events are read once the handler returns.
+func (t *Test) Stream(baseURL, endpoint string, timeout time.Duration, until EventCondition) *Test {
+	t.Endpoint = endpoint
+	t.Method = http.MethodGet
+
+	if t.Skipped {
+		return t
+	}
+
+	t.streamURL = baseURL + endpoint
+	return t.stream("", timeout, until)
+}
+
+// Reconnect opens the event stream of the test again in a sub-test, sending
+// the ID of the last event received as Last-Event-ID, after the retry delay
+// the stream set, as a browser reconnects. The sub-test's events are the ones
+// received after reconnecting.
+func (t *Test) Reconnect(timeout time.Duration, until EventCondition) *Test {
+	testCase := t.NewTest(fmt.Sprintf("reconnect %s", t.Name))
+	testCase.Endpoint = t.Endpoint
+	testCase.Method = http.MethodGet
+	testCase.streamURL = t.streamURL
+	testCase.Skipped = t.Skipped
+
+	if testCase.Skipped {
+		return testCase
+	}
+
+	if t.streamURL == "" {
+		testCase.Error = fmt.Errorf("no event stream to reconnect to, must have a stream before reconnecting")
+		return testCase
+	}
+
+	time.Sleep(t.retry)
+	return testCase.stream(t.lastEventID, timeout, until)
+}
+
+func (t *Test) stream(lastEventID string, timeout time.Duration, until EventCondition) *Test {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.streamURL, nil)
+	if err != nil {
+		t.Error = err
+		return t
+	}
+	t.request = req
+
+	req.Header = t.Header.Clone()
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+	req.Header.Set("Cache-Control", "no-cache")
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	for _, c := range t.Cookies {
+		req.AddCookie(c)
+	}
+
+	if t.Signer != nil {
+		if err := t.Signer.Sign(req, nil); err != nil {
+			t.Error = err
+			return t
+		}
+	}
+
+	client := t.Client
+	if req.URL.Scheme == "unix" {
+		if client, req, err = unixRequest(client, req); err != nil {
+			t.Error = err
+			return t
+		}
+	}
+
+	start := time.Now()
+	t.Timing = Timing{Start: start}
+	res, err := client.Do(req)
+	if err != nil {
+		t.Error = err
+		return t
+	}
+	defer res.Body.Close()
+
+	t.Response = res
+	t.Status = res.StatusCode
+
+	if res.StatusCode != http.StatusOK {
+		t.responseBody, _ = ioutil.ReadAll(res.Body)
+		t.Error = fmt.Errorf("expected status code response of %d for event stream, actual %d", http.StatusOK, res.StatusCode)
+		return t
+	}
+	if err := checkContentType(res, "text/event-stream"); err != nil {
+		t.Error = err
+		return t
+	}
+
+	er := &eventReader{r: bufio.NewReader(res.Body)}
+	t.Events = []Event{}
+	for until == nil || !until(t.Events) {
+		var event Event
+		if event, err = er.next(); err != nil {
+			break
+		}
+		t.Events = append(t.Events, event)
+	}
+
+	t.Duration = time.Since(start)
+	t.Timing.Total = t.Duration
+	t.responseBody = er.raw.Bytes()
+	t.lastEventID = er.lastEventID
+	t.retry = er.retry
+	res.Body = ioutil.NopCloser(bytes.NewReader(t.responseBody))
+
+	switch {
+	case err == nil || until == nil && (err == io.EOF || ctx.Err() != nil):
+	case ctx.Err() != nil:
+		t.Error = fmt.Errorf("timed out after %s waiting for events, received %d", timeout, len(t.Events))
+	case err == io.EOF:
+		t.Error = fmt.Errorf("event stream ended before the condition was met, received %d events", len(t.Events))
+	default:
+		t.Error = err
+	}
+
+	t.checkLatencyBudget()
+
+	return t
+}
+
+// event returns the event at the index, counting back from the last event
+// for negative indexes.
+func (t *Test) event(i int) (Event, error) {
+	j := i
+	if j < 0 {
+		j += len(t.Events)
+	}
+	if j < 0 || j >= len(t.Events) {
+		return Event{}, fmt.Errorf("expected event %d, actual %d events", i, len(t.Events))
+	}
+	return t.Events[j], nil
+}
+
+// eventData returns the data of the event, or the value at the JSON Pointer,
+// such as /order/id, in its JSON data. Values that are not strings are
+// returned as JSON.
+func eventData(event Event, pointer string) (string, error) {
+	if pointer == "" {
+		return event.Data, nil
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal([]byte(event.Data), &doc); err != nil {
+		return "", fmt.Errorf("event data is not JSON: %s", err)
+	}
+
+	path, err := parsePointer(pointer)
+	if err != nil {
+		return "", err
+	}
+	value, err := pointerGet(doc, path)
+	if err != nil {
+		return "", fmt.Errorf("event data %s: %s", pointer, err)
+	}
+
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	b, err := json.Marshal(value)
+	return string(b), err
+}
+
+// MustEventCount sets the Test.Error if the stream did not deliver the
+// number of events.
+func (t *Test) MustEventCount(count int) *Test {
+	if t.Error != nil {
+		return t
+	}
+
+	if len(t.Events) != count {
+		t.Error = fmt.Errorf("expected %d events, actual %d", count, len(t.Events))
+	}
+
+	return t
+}
+
+// MustEventType sets the Test.Error if the event at the index, negative
+// from the last event, is not of the type.
+func (t *Test) MustEventType(i int, eventType string) *Test {
+	if t.Error != nil {
+		return t
+	}
+
+	event, err := t.event(i)
+	if err != nil {
+		t.Error = err
+		return t
+	}
+
+	if event.Type != eventType {
+		t.Error = fmt.Errorf("expected event %d of type %s, actual %s", i, eventType, event.Type)
+	}
+
+	return t
+}
+
+// MustEventID sets the Test.Error if the ID of the event at the index,
+// negative from the last event, fails the matcher.
+func (t *Test) MustEventID(i int, m Matcher) *Test {
+	if t.Error != nil {
+		return t
+	}
+
+	event, err := t.event(i)
+	if err != nil {
+		t.Error = err
+		return t
+	}
+
+	if err := m(event.ID); err != nil {
+		t.Error = fmt.Errorf("event %d id: %s", i, err)
+	}
+
+	return t
+}
+
+// MustEventData sets the Test.Error if the data of the event at the index,
+// negative from the last event, fails the matcher. A JSON Pointer, such as
+// /order/id, matches a value in JSON data instead of the whole data.
+func (t *Test) MustEventData(i int, pointer string, m Matcher) *Test {
+	if t.Error != nil {
+		return t
+	}
+
+	event, err := t.event(i)
+	if err != nil {
+		t.Error = err
+		return t
+	}
+
+	value, err := eventData(event, pointer)
+	if err != nil {
+		t.Error = fmt.Errorf("event %d: %s", i, err)
+		return t
+	}
+
+	if err := m(value); err != nil {
+		t.Error = fmt.Errorf("event %d data %s: %s", i, pointer, err)
+		if pointer == "" {
+			t.Error = fmt.Errorf("event %d data: %s", i, err)
+		}
+	}
+
+	return t
+}
+
+// SaveEventData will save the data of the event at the index, negative from
+// the last event, or the value at the JSON Pointer in its JSON data, with the
+// provided name as the savedName in the test.
+func (t *Test) SaveEventData(i int, pointer, savedName string) *Test {
+	if t.Error != nil {
+		return t
+	}
+
+	event, err := t.event(i)
+	if err != nil {
+		t.Error = err
+		return t
+	}
+
+	value, err := eventData(event, pointer)
+	if err != nil {
+		t.Error = fmt.Errorf("event %d: %s", i, err)
+		return t
+	}
+
+	if t.savedValues == nil {
+		t.savedValues = map[string]string{}
+	}
+	t.savedValues[savedName] = value
+
+	return t
+}
@@ -0,0 +1,169 @@
+package irest
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// streamOrderEvents streams an order's events from the one after
+// Last-Event-ID, flushing each, then keeps the stream open until the client
+// goes away.
+func streamOrderEvents(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("X-Last-Event-ID", r.Header.Get("Last-Event-ID"))
+	flusher := w.(http.Flusher)
+
+	fmt.Fprint(w, ": order events\nretry: 10\n\n")
+	start, _ := strconv.Atoi(r.Header.Get("Last-Event-ID"))
+	for id := start + 1; id <= 4; id++ {
+		eventType := "progress"
+		if id == 4 {
+			eventType = "shipped"
+		}
+		fmt.Fprintf(w, "id: %d\nevent: %s\ndata: {\"order\":{\"id\":\"o-1\",\"step\":%d}}\n\n", id, eventType, id)
+		flusher.Flush()
+	}
+
+	<-r.Context().Done()
+}
+
+func TestEventReader(t *testing.T) {
+	stream := "\ufeff: comment\r\ndata: one\r\n\r\nevent: add\rdata: two\rdata:three\r\rid: 7\nretry: 250\nretry: soon\ndata\n\nid\n\ndata: last"
+
+	er := &eventReader{r: bufio.NewReader(strings.NewReader(stream))}
+	var events []Event
+	for {
+		event, err := er.next()
+		if err != nil {
+			break
+		}
+		events = append(events, event)
+	}
+
+	expected := []Event{
+		{Type: "message", Data: "one"},
+		{Type: "add", Data: "two\nthree"},
+		{ID: "7", Type: "message", Data: ""},
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("expected events %+v, got %+v", expected, events)
+	}
+	if er.lastEventID != "" {
+		t.Errorf("expected the empty id field to reset the last event ID, got %s", er.lastEventID)
+	}
+	if er.retry != 250*time.Millisecond {
+		t.Errorf("expected retry of 250ms, got %s", er.retry)
+	}
+	if string(er.raw.Bytes()) != stream {
+		t.Errorf("expected the raw stream kept for reports")
+	}
+}
+
+func TestStream(t *testing.T) {
+	test := NewTest("order events").Stream(api.URL, "/events/orders/o-1", 5*time.Second, UntilType("shipped")).
+		MustStatus(http.StatusOK).
+		MustEventCount(4).
+		MustEventType(0, "progress").
+		MustEventType(-1, "shipped").
+		MustEventID(-1, Equals("4")).
+		MustEventData(1, "/order/step", Equals("2")).
+		MustEventData(1, "/order/id", Equals("o-1")).
+		MustEventData(0, "", Contains(`"step":1`)).
+		SaveEventData(-1, "/order/id", "orderID")
+	if test.Error != nil {
+		t.Fatal(test.Error)
+	}
+	if test.request.Header.Get("Accept") != "text/event-stream" {
+		t.Errorf("expected the stream to be requested, got Accept %s", test.request.Header.Get("Accept"))
+	}
+	if orderID, ok := test.Saved("orderID"); orderID != "o-1" {
+		t.Errorf("expected saved order ID o-1, got %q, %t", orderID, ok)
+	}
+
+	get := &Endpoint{Path: "/orders/o-1", Method: http.MethodGet}
+	if e := test.Use(get, api.URL, nil).UseHeader("orderID", "X-Order-ID"); e.Error != nil || e.Header.Get("X-Order-ID") != "o-1" {
+		t.Errorf("expected the saved order ID sent as a header, got %q, %v", e.Header.Get("X-Order-ID"), e.Error)
+	}
+}
+
+func TestStreamReconnect(t *testing.T) {
+	test := NewTest("order events").Stream(api.URL, "/events/orders/o-1", 5*time.Second, UntilCount(2))
+	if test.Error != nil {
+		t.Fatal(test.Error)
+	}
+
+	reconnect := test.Reconnect(5*time.Second, UntilType("shipped")).
+		MustHeader("X-Last-Event-ID", Equals("2")).
+		MustEventCount(2).
+		MustEventID(0, Equals("3"))
+	if reconnect.Error != nil {
+		t.Fatal(reconnect.Error)
+	}
+	if len(test.Tests) != 1 || test.Tests[0] != reconnect {
+		t.Error("expected the reconnect to be a sub-test")
+	}
+
+	reconnect = NewTest("no stream").Reconnect(time.Second, nil)
+	if reconnect.Error == nil {
+		t.Error("expected an error reconnecting without a stream")
+	}
+}
+
+func TestStreamTimeout(t *testing.T) {
+	test := NewTest("never").Stream(api.URL, "/events", 200*time.Millisecond, UntilType("cancelled"))
+	if test.Error == nil || !strings.Contains(test.Error.Error(), "timed out after 200ms waiting for events, received 4") {
+		t.Errorf("expected a timeout error, got %v", test.Error)
+	}
+
+	test = NewTest("collect").Stream(api.URL, "/events", 200*time.Millisecond, nil).MustEventCount(4)
+	if test.Error != nil {
+		t.Errorf("expected events collected until the timeout, got %v", test.Error)
+	}
+}
+
+func TestStreamErrors(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/json" {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{}`))
+			return
+		}
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: not json\n\n"))
+	})
+
+	test := NewTest("json").Handler(handler).Stream("", "/json", time.Second, nil)
+	if test.Error == nil || !strings.Contains(test.Error.Error(), "text/event-stream") {
+		t.Errorf("expected a content type error, got %v", test.Error)
+	}
+
+	test = NewTest("missing").Handler(handler).Stream("", "/missing", time.Second, nil)
+	if test.Error == nil || !strings.Contains(test.Error.Error(), "actual 404") {
+		t.Errorf("expected a status error, got %v", test.Error)
+	}
+
+	test = NewTest("ended").Handler(handler).Stream("", "/", time.Second, UntilCount(2))
+	if test.Error == nil || !strings.Contains(test.Error.Error(), "ended before the condition was met, received 1") {
+		t.Errorf("expected an ended stream error, got %v", test.Error)
+	}
+
+	test = NewTest("data").Handler(handler).Stream("", "/", time.Second, nil).MustEventData(0, "/id", Any())
+	if test.Error == nil || !strings.Contains(test.Error.Error(), "not JSON") {
+		t.Errorf("expected a JSON error, got %v", test.Error)
+	}
+
+	test = NewTest("index").Handler(handler).Stream("", "/", time.Second, nil).MustEventType(3, "message")
+	if test.Error == nil || test.Error.Error() != "expected event 3, actual 1 events" {
+		t.Errorf("expected an index error, got %v", test.Error)
+	}
+}
@@ -0,0 +1,159 @@
+package irest
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// EndpointStats aggregates the requests made to one endpoint, grouped by the
+// method and path template.
+type EndpointStats struct {
+	Method    string        `json:"method"`
+	Template  string        `json:"template"`
+	Count     int           `json:"count"`
+	Errors    int           `json:"errors"`
+	ErrorRate float64       `json:"errorRate"`
+	Min       time.Duration `json:"min"`
+	Mean      time.Duration `json:"mean"`
+	P50       time.Duration `json:"p50"`
+	P90       time.Duration `json:"p90"`
+	P99       time.Duration `json:"p99"`
+	Max       time.Duration `json:"max"`
+}
+
+// percentile returns the nearest-rank percentile of sorted durations.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// newEndpointStats computes the statistics of a group of durations.
+func newEndpointStats(method, template string, durations []time.Duration, errors int) EndpointStats {
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+
+	s := EndpointStats{
+		Method:   method,
+		Template: template,
+		Count:    len(sorted),
+		Errors:   errors,
+	}
+
+	if s.Count > 0 {
+		s.ErrorRate = float64(errors) / float64(s.Count)
+		s.Min = sorted[0]
+		s.Max = sorted[len(sorted)-1]
+		s.Mean = total / time.Duration(s.Count)
+		s.P50 = percentile(sorted, 50)
+		s.P90 = percentile(sorted, 90)
+		s.P99 = percentile(sorted, 99)
+	}
+
+	return s
+}
+
+// endpointStats groups the requests of the result tree by method and path
+// template. Requests from a Test are grouped under any known template that
+// matches their path, known templates being those of endpoint tests and
+// latency budgets, otherwise under the path itself. Skipped tests are not
+// counted.
+func endpointStats(root *result, budgets latencyBudgets) []EndpointStats {
+	requests := []*result{}
+	templates := budgets.templates()
+	known := map[string]bool{}
+	for template := range budgets {
+		known[template] = true
+	}
+	root.walk(func(r *result) {
+		// Load and race tests are summed up by their own tables.
+		if !r.requested() || r.Skipped || r.aggregate() {
+			return
+		}
+		requests = append(requests, r)
+		if r.Template != r.Endpoint && !known[r.Template] {
+			known[r.Template] = true
+			templates = append(templates, newEndpointTemplate(r.Template))
+		}
+	})
+
+	type group struct {
+		method, template string
+		durations        []time.Duration
+		errors           int
+	}
+	groups := map[string]*group{}
+	order := []string{}
+
+	for _, r := range requests {
+		template := r.Template
+		if template == r.Endpoint {
+			template = strings.SplitN(r.Endpoint, "?", 2)[0]
+			if t, ok := mostSpecific(templates, template); ok {
+				template = t.template
+			}
+		}
+
+		key := r.Method + " " + template
+		g, ok := groups[key]
+		if !ok {
+			g = &group{method: r.Method, template: template}
+			groups[key] = g
+			order = append(order, key)
+		}
+
+		g.durations = append(g.durations, r.Duration)
+		if r.failed() {
+			g.errors++
+		}
+	}
+
+	stats := make([]EndpointStats, 0, len(order))
+	for _, key := range order {
+		g := groups[key]
+		stats = append(stats, newEndpointStats(g.method, g.template, g.durations, g.errors))
+	}
+
+	return stats
+}
+
+// Stats returns the latency statistics of each endpoint requested in the
+// test tree, in the order the endpoints were first requested.
+func (r *Report) Stats() ([]EndpointStats, error) {
+	if r.Test == nil {
+		return nil, fmt.Errorf("Report.Test must be set")
+	}
+
+	return endpointStats(newResult(r.Test, "", 0), r.Test.treeBudgets()), nil
+}
+
+// millis formats a duration in milliseconds for tables.
+func millis(d time.Duration) string {
+	return fmt.Sprintf("%.1f", float64(d)/float64(time.Millisecond))
+}
+
+// writeStatsTable writes the endpoint statistics as an aligned table.
+func writeStatsTable(w io.Writer, stats []EndpointStats) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "METHOD\tENDPOINT\tCOUNT\tMIN ms\tMEAN ms\tP50 ms\tP90 ms\tP99 ms\tMAX ms\tERRORS")
+	for _, s := range stats {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s\t%.1f%%\n",
+			s.Method, s.Template, s.Count, millis(s.Min), millis(s.Mean), millis(s.P50), millis(s.P90), millis(s.P99), millis(s.Max), s.ErrorRate*100)
+	}
+	tw.Flush()
+}
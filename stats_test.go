@@ -0,0 +1,160 @@
+package irest
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	sorted := []time.Duration{}
+	for i := 1; i <= 100; i++ {
+		sorted = append(sorted, time.Duration(i)*time.Millisecond)
+	}
+
+	var percentileTests = []struct {
+		p   float64
+		out time.Duration
+	}{
+		{0, time.Millisecond},
+		{50, 50 * time.Millisecond},
+		{90, 90 * time.Millisecond},
+		{99, 99 * time.Millisecond},
+		{100, 100 * time.Millisecond},
+	}
+
+	for _, pt := range percentileTests {
+		if d := percentile(sorted, pt.p); d != pt.out {
+			t.Errorf("expected p%.0f to be %s, got %s", pt.p, pt.out, d)
+		}
+	}
+
+	if d := percentile(nil, 50); d != 0 {
+		t.Errorf("expected 0 for no durations, got %s", d)
+	}
+}
+
+func TestNewEndpointStats(t *testing.T) {
+	durations := []time.Duration{
+		40 * time.Millisecond,
+		10 * time.Millisecond,
+		30 * time.Millisecond,
+		20 * time.Millisecond,
+	}
+
+	s := newEndpointStats("GET", "/things/%d", durations, 1)
+
+	expected := EndpointStats{
+		Method:    "GET",
+		Template:  "/things/%d",
+		Count:     4,
+		Errors:    1,
+		ErrorRate: 0.25,
+		Min:       10 * time.Millisecond,
+		Mean:      25 * time.Millisecond,
+		P50:       20 * time.Millisecond,
+		P90:       40 * time.Millisecond,
+		P99:       40 * time.Millisecond,
+		Max:       40 * time.Millisecond,
+	}
+
+	if s != expected {
+		t.Errorf("expected %+v, got %+v", expected, s)
+	}
+}
+
+func TestReportStatsGroupedByTemplate(t *testing.T) {
+	getThing := &Endpoint{Path: "/things/%d", Method: http.MethodGet}
+
+	test := NewTest("unit-test")
+	test.NewEndpointsTest("scenario",
+		getThing.Use(api.URL, nil, 1).Do(),
+		getThing.Use(api.URL, nil, 2).Do(),
+	)
+	test.NewTest("get").Get(api.URL, "/things/3")
+	test.NewTest("failed get").Get(api.URL, "/things/4?full=true").MustStatus(http.StatusNotFound)
+	test.NewTest("post").Post(api.URL, "/things", nil)
+	test.NewTest("skipped").Skip().Get(api.URL, "/things/5")
+
+	report := NewPlainCommandLineReport(test)
+
+	stats, err := report.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stats) != 2 {
+		t.Fatalf("expected 2 endpoints, got %+v", stats)
+	}
+
+	if stats[0].Method != http.MethodGet || stats[0].Template != "/things/%d" || stats[0].Count != 4 || stats[0].Errors != 1 {
+		t.Errorf("unexpected stats for GET /things/%%d: %+v", stats[0])
+	}
+
+	if stats[1].Method != http.MethodPost || stats[1].Template != "/things" || stats[1].Count != 1 {
+		t.Errorf("unexpected stats for POST /things: %+v", stats[1])
+	}
+
+	var b bytes.Buffer
+	if err := report.WriteReport(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(b.String(), "METHOD  ENDPOINT") || !strings.Contains(b.String(), "25.0%") {
+		t.Error("expected stats table in report output:", b.String())
+	}
+
+	b.Reset()
+	if err := report.WriteJSON(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	jr, err := ReadJSONReport(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(jr.Endpoints) != 2 || jr.Endpoints[0] != stats[0] {
+		t.Errorf("expected json report endpoints to match stats, got %+v", jr.Endpoints)
+	}
+}
+
+func TestReportStatsFailedRequests(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	getThing := &Endpoint{Path: "/things/%d", Method: http.MethodGet}
+
+	test := NewTest("unit-test")
+	test.NewTest("get").Get(api.URL, "/things/1")
+	test.NewTest("refused get").Get(server.URL, "/things/2")
+	test.NewEndpointsTest("scenario", test.Use(getThing, server.URL, nil, 3).Do())
+
+	stats, err := NewPlainCommandLineReport(test).Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stats) != 1 || stats[0].Method != http.MethodGet || stats[0].Template != "/things/%d" || stats[0].Count != 3 || stats[0].Errors != 2 {
+		t.Errorf("expected requests without a response grouped under GET /things/%%d, got %+v", stats)
+	}
+}
+
+func TestReportStatsBudgetTemplate(t *testing.T) {
+	test := NewTest("unit-test").
+		SetLatencyBudget("/things/%d", time.Second)
+	test.NewTest("first").Get(api.URL, "/things/1")
+	test.NewTest("second").Get(api.URL, "/things/2")
+
+	stats, err := NewPlainCommandLineReport(test).Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stats) != 1 || stats[0].Template != "/things/%d" || stats[0].Count != 2 {
+		t.Errorf("expected requests grouped under budget template, got %+v", stats)
+	}
+}
@@ -0,0 +1,78 @@
+package irest
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// WriteTAP writes every test that made a request as a TAP version 13 test
+// point. Failed and slow tests have a YAML diagnostic block with the errors
+// and request details, and skipped tests use the SKIP directive.
+func (r *Report) WriteTAP(w io.Writer) error {
+	if r.Test == nil {
+		return fmt.Errorf("Report.Test must be set")
+	}
+
+	requests := newResult(r.Test, "", 0).requests()
+
+	var b strings.Builder
+	b.WriteString("TAP version 13\n")
+	fmt.Fprintf(&b, "1..%d\n", len(requests))
+
+	for i, t := range requests {
+		status := "ok"
+		if t.failed() {
+			status = "not ok"
+		}
+
+		fmt.Fprintf(&b, "%s %d - %s", status, i+1, tapEscape(t.Path))
+		if t.Skipped {
+			b.WriteString(" # SKIP")
+		}
+		b.WriteString("\n")
+
+		if t.failed() || t.slow() {
+			writeTAPDiagnostic(&b, t)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// tapEscape escapes the characters that would start a directive or be read
+// as part of one in a test point description.
+func tapEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "#", `\#`, "\n", " ").Replace(s)
+}
+
+func writeTAPDiagnostic(b *strings.Builder, t *result) {
+	b.WriteString("  ---\n")
+
+	if failures := t.failures(); len(failures) > 0 {
+		fmt.Fprintf(b, "  message: %s\n", strconv.Quote(failures[0]))
+		b.WriteString("  severity: fail\n")
+		if len(failures) > 1 {
+			b.WriteString("  errors:\n")
+			for _, msg := range failures {
+				fmt.Fprintf(b, "    - %s\n", strconv.Quote(msg))
+			}
+		}
+	} else {
+		fmt.Fprintf(b, "  message: %s\n", strconv.Quote(t.LatencyError.Error()))
+		b.WriteString("  severity: slow\n")
+	}
+
+	b.WriteString("  data:\n")
+	fmt.Fprintf(b, "    method: %s\n", strconv.Quote(t.Method))
+	fmt.Fprintf(b, "    endpoint: %s\n", strconv.Quote(t.Endpoint))
+	fmt.Fprintf(b, "    status: %d\n", t.Status)
+	fmt.Fprintf(b, "    duration: %s\n", strconv.Quote(t.Duration.String()))
+	if t.LatencyError != nil && t.failed() {
+		fmt.Fprintf(b, "    latency: %s\n", strconv.Quote(t.LatencyError.Error()))
+	}
+
+	b.WriteString("  ...\n")
+}
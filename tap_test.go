@@ -0,0 +1,60 @@
+package irest
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteTAP(t *testing.T) {
+	test := NewTest("unit-test")
+	test.NewTest("get").Get(api.URL, "/tests").MustStatus(http.StatusOK)
+	test.NewTest("failed #1").Get(api.URL, "/tests").MustStatus(http.StatusNotFound)
+	test.NewTest("skipped").Skip().Get(api.URL, "/tests")
+	test.NewTest("slow").Get(api.URL, "/tests").MustRespondWithin(time.Nanosecond)
+
+	var b bytes.Buffer
+	if err := NewPlainCommandLineReport(test).WriteTAP(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"TAP version 13\n1..4\n",
+		"ok 1 - unit-test/get\n",
+		"not ok 2 - unit-test/failed \\#1\n  ---\n  message: \"expected status code response of 404, actual 200\"\n  severity: fail\n  data:\n    method: \"GET\"\n    endpoint: \"/tests\"\n    status: 200\n",
+		"ok 3 - unit-test/skipped # SKIP\n",
+		"ok 4 - unit-test/slow\n  ---\n  message: \"expected response within 1ns",
+		"  severity: slow\n",
+	}
+
+	output := b.String()
+	for _, expect := range expected {
+		if !strings.Contains(output, expect) {
+			t.Errorf("tap output should contain %q, got:\n%s", expect, output)
+		}
+	}
+
+	if !strings.HasSuffix(output, "  ...\n") {
+		t.Error("expected yaml block to be closed:", output)
+	}
+}
+
+func TestWriteTAPReporter(t *testing.T) {
+	test := NewTest("unit-test")
+	test.NewTest("get").Get(api.URL, "/tests")
+
+	var console, tap bytes.Buffer
+	report := NewPlainCommandLineReport(test)
+	report.Output = &console
+	report.AddReporter(&tap, ReporterFunc(report.WriteTAP))
+
+	if err := report.PrintResults(); err != nil {
+		t.Fatal(err)
+	}
+
+	if tap.String() != "TAP version 13\n1..1\nok 1 - unit-test/get\n" {
+		t.Errorf("unexpected tap output:\n%s", tap.String())
+	}
+}
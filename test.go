@@ -5,7 +5,6 @@ package irest
 
 import (
 	"bytes"
-	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -21,21 +20,65 @@ type Test struct {
 	Method   string `json:"method"`
 	Status   int    `json:"status"`
 
+	// LatencyError is set when the response was slower than allowed, kept
+	// apart from Error so slow and broken responses can be told apart.
+	LatencyError error `json:"latencyErr"`
+
+	// Skipped tests do not make requests and are not counted as passed or
+	// failed, whatever their checks report.
+	Skipped bool `json:"skipped"`
+
 	Tests    []*Test
 	Errors   []error
-	Created  time.Time `json:"created"`
-	Duration int64     `json:"duration"`
+	Created  time.Time     `json:"created"`
+	Duration time.Duration `json:"duration"`
+	Timing   Timing        `json:"timing"`
 	Depth    int
 
+	// Compression holds the content coding and sizes of an encoded response.
+	Compression *Compression `json:"compression,omitempty"`
+
+	// Events are the Server-Sent Events received with Stream.
+	Events []Event `json:"events,omitempty"`
+
+	// LoadResult holds the measurements of a load test run with Load.
+	LoadResult *LoadResult `json:"-"`
+
+	// RaceResult holds the responses of concurrent requests made with
+	// RaceTest.
+	RaceResult *RaceResult `json:"-"`
+
 	// EndpointTests are an abstracted slice of tests for specific endpoints.
 	EndpointTests []*EndpointTest
-	savedValues map[string]string
+	savedValues   map[string]string
+	budgets       latencyBudgets
+
+	// middleware wraps transport to make the test's client.
+	middleware []Middleware
+	transport  http.RoundTripper
+
+	// requestEncoding compresses request bodies when set, and acceptEncoding
+	// is sent as Accept-Encoding.
+	requestEncoding string
+	acceptEncoding  string
+
+	// streamURL is the event stream opened with Stream, and lastEventID and
+	// retry what it last set for reconnecting.
+	streamURL   string
+	lastEventID string
+	retry       time.Duration
 
 	// HTTP related fields for making requests and getting responses.
 	Client   *http.Client
+	Signer   Signer
 	Header   *http.Header
 	Cookies  []*http.Cookie
 	Response *http.Response
+
+	// request is the last request made, kept for reports.
+	request      *http.Request
+	requestBody  []byte
+	responseBody []byte
 }
 
 // NewTest creates a new test with a given name.
@@ -47,8 +90,11 @@ func NewTest(name string) *Test {
 		Tests:   []*Test{},
 		Errors:  []error{},
 		Created: time.Now(),
-		Client:  &http.Client{},
+		Client:  &http.Client{Jar: NewCookieJar()},
 		Header:  &http.Header{},
+		budgets: latencyBudgets{},
+
+		savedValues: map[string]string{},
 	}
 
 	return t
@@ -58,12 +104,20 @@ func NewTest(name string) *Test {
 // individual test cases.
 func (t *Test) NewTest(name string) *Test {
 	testCase := &Test{
-		Name:   name,
-		Depth:  t.Depth + 1,
-		Tests:  []*Test{},
-		Client: t.Client,
-		Header: &http.Header{},
+		Name:        name,
+		Depth:       t.Depth + 1,
+		Tests:       []*Test{},
+		Client:      t.Client,
+		Signer:      t.Signer,
+		Header:      &http.Header{},
+		Created:     time.Now(),
 		savedValues: make(map[string]string),
+		budgets:     t.budgets,
+		middleware:  t.middleware,
+		transport:   t.transport,
+
+		requestEncoding: t.requestEncoding,
+		acceptEncoding:  t.acceptEncoding,
 	}
 
 	t.Tests = append(t.Tests, testCase)
@@ -75,22 +129,46 @@ func (t *Test) NewTest(name string) *Test {
 	return testCase
 }
 
-// NewEndpointsTest tests endpoints in order with more abstracted functionality.
+// NewEndpointsTest adds a sub-test holding the endpoint tests in order with
+// more abstracted functionality. Endpoint tests not yet bound to a test are
+// bound to the sub-test. Ones already sent unbound fail if the test
+// configures its requests, as they were made without that configuration.
 func (t *Test) NewEndpointsTest(name string, tests ...*EndpointTest) *Test {
-	testCase := &EndpointTest{
-		Parent: t,
-		Name:   name,
-		Client: t.Client,
-		Header: &http.Header{},
+	testCase := t.NewTest(name)
+
+	for _, e := range tests {
+		switch {
+		case e.Parent != nil:
+		case e.Request == nil && e.Response == nil && !e.Skipped:
+			e.In(testCase)
+		default:
+			// An endpoint test made before being bound used a bare client,
+			// bypassing anything the test configures for its requests.
+			if e.Error == nil && testCase.configuresRequests() {
+				e.Error = fmt.Errorf("endpoint test was sent before being bound to test %s, bypassing its client, middleware, signer and compression; create it with Test.Use or bind it with In before Do", name)
+			}
+			e.Parent = testCase
+		}
+		e.checkLatencyBudget()
+		testCase.EndpointTests = append(testCase.EndpointTests, e)
 	}
 
-	// For convenience, bring down header values that were set on the
-	// parent test.
-	testCase.Header = t.Header
+	return testCase
+}
 
+// Skip marks the test as skipped so no request is made for it.
+func (t *Test) Skip() *Test {
+	t.Skipped = true
 	return t
 }
 
+// Saved returns the value saved in the test with the name, by SaveHeader,
+// SaveXPath or SaveEventData, and whether one was saved.
+func (t *Test) Saved(name string) (string, bool) {
+	value, ok := t.savedValues[name]
+	return value, ok
+}
+
 // AddHeader is a utility function to just wrap setting a header with a value
 // by name.
 func (t *Test) AddHeader(name, value string) *Test {
@@ -126,47 +204,95 @@ func (t *Test) Delete(baseURL, endpoint string) *Test {
 	return t.do(http.MethodDelete, baseURL, endpoint, nil)
 }
 
+// Patch sends a HTTP PATCH request with given URL from baseURL combined with
+// endpoint and sends the data, such as a JSONPatch or MergePatch, as request
+// body.
+func (t *Test) Patch(baseURL, endpoint string, data interface{}) *Test {
+	return t.do(http.MethodPatch, baseURL, endpoint, data)
+}
+
+// Head retrieves only the headers of a specified endpoint.
+func (t *Test) Head(baseURL, endpoint string) *Test {
+	return t.do(http.MethodHead, baseURL, endpoint, nil)
+}
+
+// Options retrieves the communication options of a specified endpoint.
+func (t *Test) Options(baseURL, endpoint string) *Test {
+	return t.do(http.MethodOptions, baseURL, endpoint, nil)
+}
+
+// Request sends a HTTP request with any method with given URL from baseURL
+// combined with endpoint and sends the data, when not nil, as request body.
+func (t *Test) Request(method, baseURL, endpoint string, data interface{}) *Test {
+	return t.do(method, baseURL, endpoint, data)
+}
+
 func (t *Test) do(method, baseURL, endpoint string, data interface{}) *Test {
 	t.Endpoint = endpoint
+	t.Method = method
 
-	b := new(bytes.Buffer)
-	if data != nil {
-		if err := json.NewEncoder(b).Encode(data); err != nil {
-			t.Error = err
-			return t
-		}
+	if t.Skipped {
+		return t
 	}
 
-	req, err := http.NewRequest(method, baseURL+endpoint, b)
+	contentType, body, err := encodeBody(data)
 	if err != nil {
 		t.Error = err
 		return t
 	}
 
-	req.Header = *t.Header
+	t.requestBody = body
+	req, err := http.NewRequest(method, baseURL+endpoint, bytes.NewReader(body))
+	if err != nil {
+		t.Error = err
+		return t
+	}
+	t.request = req
+
+	req.Header = t.Header.Clone()
+	setBodyHeaders(req.Header, data, contentType)
+	setAcceptEncoding(req, t.acceptEncoding)
 
 	for _, c := range t.Cookies {
 		req.AddCookie(c)
 	}
 
-	startTime := time.Now()
-	res, err := t.Client.Do(req)
+	if body, err = compressRequest(req, t.requestEncoding, body); err != nil {
+		t.Error = err
+		return t
+	}
+
+	if t.Signer != nil {
+		if err := t.Signer.Sign(req, body); err != nil {
+			t.Error = err
+			return t
+		}
+	}
+
+	res, body, timing, err := send(t.Client, req)
+	t.Timing = timing
+	t.Duration = timing.Total
 	if err != nil {
 		t.Error = err
 		return t
 	}
-	reqDuration := time.Since(startTime)
-	t.Duration = reqDuration.Nanoseconds() / int64(time.Millisecond)
+
+	if body, t.Compression, err = decompress(res, body); err != nil {
+		t.Error = err
+		return t
+	}
 
 	t.Response = res
-	t.Method = method
+	t.responseBody = body
 	t.Status = res.StatusCode
 
+	t.checkLatencyBudget()
+
 	return t
 }
 
-// ParseResponseBody parses the HTTP response body from json
-// to a provided interface.
+// ParseResponseBody parses the HTTP response body to a provided interface
+// with the decoder for its Content-Type: JSON, XML, form or text.
 func (t *Test) ParseResponseBody(result interface{}) *Test {
 	if t.Response == nil || t.Response.Body == nil {
 		t.Error = fmt.Errorf("need response body to parse")
@@ -182,7 +308,7 @@ func (t *Test) ParseResponseBody(result interface{}) *Test {
 		return t
 	}
 
-	if err := json.Unmarshal(resultBody, result); err != nil {
+	if err := decodeBody(t.Response.Header.Get("Content-Type"), resultBody, result); err != nil {
 		t.Error = err
 		return t
 	}
@@ -12,6 +12,19 @@ import (
 
 var api *httptest.Server
 
+// apiRoutes serve the requests to api by the first segment of their path, for
+// the features the sample handler does not cover.
+var apiRoutes = map[string]http.HandlerFunc{
+	"cookies": setCookies,
+	"slow":    respondSlowly,
+	"echo":    echoHeaders,
+	"body":    echoBody,
+	"load":    countLoad,
+	"events":  streamOrderEvents,
+	"signed":  checkSignature,
+	"session": serveSession,
+}
+
 type SampleObject struct {
 	Name    string
 	Value   int
@@ -21,7 +34,9 @@ type SampleObject struct {
 func init() {
 	api = httptest.NewServer(
 		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if r.Method == http.MethodPost || r.Method == http.MethodPut {
+			if route, ok := apiRoutes[strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)[0]]; ok {
+				route(w, r)
+			} else if r.Method == http.MethodPost || r.Method == http.MethodPut {
 				data, _ := json.Marshal(SampleObject{
 					Name:    "unit-test",
 					Value:   100,
@@ -0,0 +1,310 @@
+package irest
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// httpTransport returns a copy of the test's base transport to change, so
+// the transports of other tests are left unchanged.
+func (t *Test) httpTransport() (*http.Transport, error) {
+	if t.Client == nil {
+		t.Client = &http.Client{}
+	}
+
+	base := t.transport
+	if base == nil {
+		base = t.Client.Transport
+	}
+	if base == nil {
+		base = baseTransport
+	}
+
+	transport, ok := base.(*http.Transport)
+	if !ok {
+		return nil, fmt.Errorf("test transport is %T, not *http.Transport", base)
+	}
+
+	return transport.Clone(), nil
+}
+
+// setTransport makes the transport the base of the test's client, under its
+// middleware.
+func (t *Test) setTransport(transport http.RoundTripper) {
+	t.transport = transport
+	t.rebuildClient()
+}
+
+// configureTLS changes the TLS configuration of the test and of sub-tests
+// created after it.
+func (t *Test) configureTLS(configure func(*tls.Config) error) *Test {
+	return t.configureTransport(func(transport *http.Transport) error {
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		return configure(transport.TLSClientConfig)
+	})
+}
+
+// AddRootCA trusts the PEM encoded certificates, such as an internal CA, in
+// addition to the system roots.
+func (t *Test) AddRootCA(pemCerts []byte) *Test {
+	return t.configureTLS(func(c *tls.Config) error {
+		if c.RootCAs == nil {
+			pool, err := x509.SystemCertPool()
+			if err != nil {
+				pool = x509.NewCertPool()
+			}
+			c.RootCAs = pool
+		} else {
+			c.RootCAs = c.RootCAs.Clone()
+		}
+
+		if !c.RootCAs.AppendCertsFromPEM(pemCerts) {
+			return fmt.Errorf("no certificates found in root CA PEM")
+		}
+		return nil
+	})
+}
+
+// AddRootCAFile trusts the PEM encoded certificates in the file.
+func (t *Test) AddRootCAFile(path string) *Test {
+	if t.Error != nil {
+		return t
+	}
+
+	pemCerts, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Error = err
+		return t
+	}
+
+	return t.AddRootCA(pemCerts)
+}
+
+// AddClientCertificate presents the certificate when servers ask for one,
+// for mutual TLS.
+func (t *Test) AddClientCertificate(cert tls.Certificate) *Test {
+	return t.configureTLS(func(c *tls.Config) error {
+		c.Certificates = append(c.Certificates[:len(c.Certificates):len(c.Certificates)], cert)
+		return nil
+	})
+}
+
+// ClientCertificate presents the PEM encoded certificate and key pair in the
+// files when servers ask for one, for mutual TLS.
+func (t *Test) ClientCertificate(certFile, keyFile string) *Test {
+	if t.Error != nil {
+		return t
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Error = err
+		return t
+	}
+
+	return t.AddClientCertificate(cert)
+}
+
+// MinTLSVersion refuses connections below the TLS version, such as
+// tls.VersionTLS12.
+func (t *Test) MinTLSVersion(version uint16) *Test {
+	return t.configureTLS(func(c *tls.Config) error {
+		c.MinVersion = version
+		return nil
+	})
+}
+
+// ServerName sets the name sent with SNI and checked against the server's
+// certificate, for servers reached by an address that is not their name.
+func (t *Test) ServerName(name string) *Test {
+	return t.configureTLS(func(c *tls.Config) error {
+		c.ServerName = name
+		return nil
+	})
+}
+
+// InsecureSkipVerify accepts any server certificate. It should only be used
+// against test servers with certificates that cannot be verified.
+func (t *Test) InsecureSkipVerify() *Test {
+	return t.configureTLS(func(c *tls.Config) error {
+		c.InsecureSkipVerify = true
+		return nil
+	})
+}
+
+// CertificateCheck checks a certificate presented by a server.
+type CertificateCheck func(*x509.Certificate) error
+
+// CertValidFor checks that the certificate does not expire within the
+// duration, such as 30 days.
+func CertValidFor(d time.Duration) CertificateCheck {
+	return func(c *x509.Certificate) error {
+		if left := time.Until(c.NotAfter); left < d {
+			return fmt.Errorf("expected certificate to be valid for %s, expires in %d days", d, int(left.Hours()/24))
+		}
+		return nil
+	}
+}
+
+// CertDNSName checks that the certificate is valid for the host name.
+func CertDNSName(name string) CertificateCheck {
+	return func(c *x509.Certificate) error {
+		if err := c.VerifyHostname(name); err != nil {
+			return fmt.Errorf("expected certificate for %s: %s", name, err)
+		}
+		return nil
+	}
+}
+
+// CertSubject checks the common name of the certificate's subject.
+func CertSubject(m Matcher) CertificateCheck {
+	return func(c *x509.Certificate) error {
+		if err := m(c.Subject.CommonName); err != nil {
+			return fmt.Errorf("certificate subject: %s", err)
+		}
+		return nil
+	}
+}
+
+// CertIssuer checks the common name of the certificate's issuer.
+func CertIssuer(m Matcher) CertificateCheck {
+	return func(c *x509.Certificate) error {
+		if err := m(c.Issuer.CommonName); err != nil {
+			return fmt.Errorf("certificate issuer: %s", err)
+		}
+		return nil
+	}
+}
+
+func connectionState(res *http.Response) (*tls.ConnectionState, error) {
+	if res == nil {
+		return nil, errNoResponse
+	}
+	if res.TLS == nil {
+		return nil, fmt.Errorf("expected a TLS connection")
+	}
+	return res.TLS, nil
+}
+
+func checkTLSVersion(res *http.Response, version uint16) error {
+	state, err := connectionState(res)
+	if err != nil {
+		return err
+	}
+	if state.Version != version {
+		return fmt.Errorf("expected %s, actual %s", tls.VersionName(version), tls.VersionName(state.Version))
+	}
+	return nil
+}
+
+func checkNegotiatedProtocol(res *http.Response, protocol string) error {
+	state, err := connectionState(res)
+	if err != nil {
+		return err
+	}
+
+	// Without ALPN the connection falls back to HTTP/1.1.
+	actual := state.NegotiatedProtocol
+	if actual == "" {
+		actual = "http/1.1"
+	}
+	if actual != protocol {
+		return fmt.Errorf("expected negotiated protocol %s, actual %s", protocol, actual)
+	}
+	return nil
+}
+
+func checkPeerCertificate(res *http.Response, checks []CertificateCheck) error {
+	state, err := connectionState(res)
+	if err != nil {
+		return err
+	}
+	if len(state.PeerCertificates) == 0 {
+		return fmt.Errorf("expected a peer certificate")
+	}
+
+	for _, check := range checks {
+		if err := check(state.PeerCertificates[0]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MustTLSVersion sets the Test.Error if the connection did not use the TLS
+// version, such as tls.VersionTLS13.
+func (t *Test) MustTLSVersion(version uint16) *Test {
+	if t.Error != nil {
+		return t
+	}
+
+	t.Error = checkTLSVersion(t.Response, version)
+
+	return t
+}
+
+// MustNegotiatedProtocol sets the Test.Error if the protocol negotiated with
+// ALPN, such as "h2", is not the expected one.
+func (t *Test) MustNegotiatedProtocol(protocol string) *Test {
+	if t.Error != nil {
+		return t
+	}
+
+	t.Error = checkNegotiatedProtocol(t.Response, protocol)
+
+	return t
+}
+
+// MustPeerCertificate sets the Test.Error if a check fails on the
+// certificate the server presented.
+func (t *Test) MustPeerCertificate(checks ...CertificateCheck) *Test {
+	if t.Error != nil {
+		return t
+	}
+
+	t.Error = checkPeerCertificate(t.Response, checks)
+
+	return t
+}
+
+// MustTLSVersion sets the EndpointTest.Error if the connection did not use
+// the TLS version, such as tls.VersionTLS13.
+func (e *EndpointTest) MustTLSVersion(version uint16) *EndpointTest {
+	if e.Error != nil {
+		return e
+	}
+
+	e.Error = checkTLSVersion(e.Response, version)
+
+	return e
+}
+
+// MustNegotiatedProtocol sets the EndpointTest.Error if the protocol
+// negotiated with ALPN, such as "h2", is not the expected one.
+func (e *EndpointTest) MustNegotiatedProtocol(protocol string) *EndpointTest {
+	if e.Error != nil {
+		return e
+	}
+
+	e.Error = checkNegotiatedProtocol(e.Response, protocol)
+
+	return e
+}
+
+// MustPeerCertificate sets the EndpointTest.Error if a check fails on the
+// certificate the server presented.
+func (e *EndpointTest) MustPeerCertificate(checks ...CertificateCheck) *EndpointTest {
+	if e.Error != nil {
+		return e
+	}
+
+	e.Error = checkPeerCertificate(e.Response, checks)
+
+	return e
+}
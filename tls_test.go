@@ -0,0 +1,215 @@
+package irest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newCertificate creates a certificate signed by the parent, or self-signed
+// when parent is nil, and returns it with its PEM encoded certificate and key.
+func newCertificate(t *testing.T, name string, parent *tls.Certificate) (tls.Certificate, []byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	signer, signerKey := template, interface{}(key)
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert.Leaf, _ = x509.ParseCertificate(der)
+
+	return cert, certPEM, keyPEM
+}
+
+// newTLSAPI starts a TLS server with HTTP/2 echoing the client certificate
+// name, requiring client certificates signed by ca when it is set.
+func newTLSAPI(ca *tls.Certificate) *httptest.Server {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := ""
+		if len(r.TLS.PeerCertificates) > 0 {
+			name = r.TLS.PeerCertificates[0].Subject.CommonName
+		}
+		fmt.Fprintf(w, `{"client":%q}`, name)
+	}))
+	server.EnableHTTP2 = true
+	if ca != nil {
+		pool := x509.NewCertPool()
+		pool.AddCert(ca.Leaf)
+		server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool}
+	}
+	server.StartTLS()
+	return server
+}
+
+func serverCAPEM(server *httptest.Server) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+}
+
+func TestRootCA(t *testing.T) {
+	server := newTLSAPI(nil)
+	defer server.Close()
+
+	untrusted := NewTest("untrusted").Get(server.URL, "/")
+	if untrusted.Error == nil || !strings.Contains(untrusted.Error.Error(), "certificate") {
+		t.Errorf("expected a certificate error without the root CA, got %v", untrusted.Error)
+	}
+
+	test := NewTest("trusted").AddRootCA(serverCAPEM(server))
+	test.NewTest("get").Get(server.URL, "/").
+		MustStatus(http.StatusOK).
+		MustTLSVersion(tls.VersionTLS13).
+		MustNegotiatedProtocol("h2").
+		MustPeerCertificate(CertDNSName("example.com"), CertValidFor(24*time.Hour), CertIssuer(Equals("")), CertSubject(Equals("")))
+	if err := test.Tests[0].Error; err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(path, serverCAPEM(server), 0600); err != nil {
+		t.Fatal(err)
+	}
+	fromFile := NewTest("file").AddRootCAFile(path).Get(server.URL, "/").MustStatus(http.StatusOK)
+	if fromFile.Error != nil {
+		t.Fatal(fromFile.Error)
+	}
+
+	if NewTest("invalid").AddRootCA([]byte("not a certificate")).Error == nil {
+		t.Error("expected an error for PEM without certificates")
+	}
+}
+
+func TestTLSConfigScope(t *testing.T) {
+	server := newTLSAPI(nil)
+	defer server.Close()
+
+	root := NewTest("root")
+	child := root.NewTest("child").InsecureSkipVerify()
+	child.Get(server.URL, "/").MustStatus(http.StatusOK)
+	if child.Error != nil {
+		t.Fatal(child.Error)
+	}
+
+	sibling := root.NewTest("sibling").Get(server.URL, "/")
+	if sibling.Error == nil {
+		t.Error("expected TLS options not to reach sibling tests")
+	}
+}
+
+func TestServerNameAndMinVersion(t *testing.T) {
+	server := newTLSAPI(nil)
+	defer server.Close()
+
+	test := NewTest("sni").AddRootCA(serverCAPEM(server)).ServerName("example.com").MinTLSVersion(tls.VersionTLS13)
+	test.Get(server.URL, "/").MustStatus(http.StatusOK).MustTLSVersion(tls.VersionTLS13)
+	if test.Error != nil {
+		t.Fatal(test.Error)
+	}
+
+	wrongName := NewTest("wrong").AddRootCA(serverCAPEM(server)).ServerName("other.test").Get(server.URL, "/")
+	if wrongName.Error == nil {
+		t.Error("expected an error for a server name not in the certificate")
+	}
+
+	old := NewTest("old").AddRootCA(serverCAPEM(server)).MinTLSVersion(tls.VersionTLS13)
+	old.Get(server.URL, "/").MustTLSVersion(tls.VersionTLS12)
+	if old.Error == nil || old.Error.Error() != "expected TLS 1.2, actual TLS 1.3" {
+		t.Errorf("expected a TLS version error, got %v", old.Error)
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	ca, _, _ := newCertificate(t, "test ca", nil)
+	client, certPEM, keyPEM := newCertificate(t, "client", &ca)
+
+	server := newTLSAPI(&ca)
+	defer server.Close()
+
+	anonymous := NewTest("anonymous").AddRootCA(serverCAPEM(server)).Get(server.URL, "/")
+	if anonymous.Error == nil {
+		t.Error("expected the server to require a client certificate")
+	}
+
+	var echo struct {
+		Client string `json:"client"`
+	}
+	test := NewTest("mtls").AddRootCA(serverCAPEM(server)).AddClientCertificate(client)
+	test.Get(server.URL, "/").MustStatus(http.StatusOK).ParseResponseBody(&echo)
+	if test.Error != nil || echo.Client != "client" {
+		t.Fatalf("expected the client certificate to be presented, got %q, %v", echo.Client, test.Error)
+	}
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key")
+	os.WriteFile(certFile, certPEM, 0600)
+	os.WriteFile(keyFile, keyPEM, 0600)
+
+	fromFiles := NewTest("files").AddRootCA(serverCAPEM(server)).ClientCertificate(certFile, keyFile)
+	fromFiles.Get(server.URL, "/").MustStatus(http.StatusOK)
+	if fromFiles.Error != nil {
+		t.Fatal(fromFiles.Error)
+	}
+}
+
+func TestTLSAssertionErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	test := NewTest("plain").Get(server.URL, "/").MustTLSVersion(tls.VersionTLS13)
+	if test.Error == nil || test.Error.Error() != "expected a TLS connection" {
+		t.Errorf("expected a TLS connection error, got %v", test.Error)
+	}
+
+	secure := newTLSAPI(nil)
+	defer secure.Close()
+
+	get := &Endpoint{Path: "/", Method: http.MethodGet}
+	e := get.Use(secure.URL, nil).In(NewTest("expiring").AddRootCA(serverCAPEM(secure))).Do().
+		MustPeerCertificate(CertValidFor(100 * 365 * 24 * time.Hour))
+	if e.Error == nil || !strings.Contains(e.Error.Error(), "expected certificate to be valid for") {
+		t.Errorf("expected an expiry error, got %v", e.Error)
+	}
+}
@@ -0,0 +1,156 @@
+package irest
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Timing breaks down where the time of a single request was spent, which
+// helps tell network slowness apart from server slowness.
+type Timing struct {
+	// Start is when the request was started.
+	Start time.Time `json:"start"`
+
+	// DNS is the time spent resolving the host.
+	DNS time.Duration `json:"dns"`
+
+	// Connect is the time spent establishing the TCP connection.
+	Connect time.Duration `json:"connect"`
+
+	// TLSHandshake is the time spent on the TLS handshake.
+	TLSHandshake time.Duration `json:"tlsHandshake"`
+
+	// TimeToFirstByte is the time from the request being written until the
+	// first byte of the response arrived, i.e. the server wait time.
+	TimeToFirstByte time.Duration `json:"timeToFirstByte"`
+
+	// Download is the time spent reading the response body.
+	Download time.Duration `json:"download"`
+
+	// Total is the time from starting the request until the body was read.
+	Total time.Duration `json:"total"`
+
+	// ConnReused is true when an idle keep-alive connection was used, in
+	// which case DNS, Connect and TLSHandshake are zero.
+	ConnReused bool `json:"connReused"`
+}
+
+// String formats the timing breakdown for display.
+func (t Timing) String() string {
+	parts := []string{
+		fmt.Sprintf("dns %s", t.DNS),
+		fmt.Sprintf("connect %s", t.Connect),
+		fmt.Sprintf("tls %s", t.TLSHandshake),
+		fmt.Sprintf("ttfb %s", t.TimeToFirstByte),
+		fmt.Sprintf("download %s", t.Download),
+	}
+	if t.ConnReused {
+		parts = append(parts, "reused connection")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// tracer records the httptrace callbacks of a request. The callbacks run on
+// transport goroutines, so its fields are guarded by mu.
+type tracer struct {
+	mu sync.Mutex
+
+	start, dnsStart, connectStart, tlsStart, wrote time.Time
+	timing                                         Timing
+}
+
+// record runs fn with the tracer locked.
+func (tr *tracer) record(fn func()) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	fn()
+}
+
+// snapshot returns a copy of the timing recorded so far.
+func (tr *tracer) snapshot() Timing {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	return tr.timing
+}
+
+func (tr *tracer) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			tr.record(func() { tr.timing.ConnReused = info.Reused })
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			tr.record(func() { tr.dnsStart = time.Now() })
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			tr.record(func() { tr.timing.DNS = time.Since(tr.dnsStart) })
+		},
+		ConnectStart: func(network, addr string) {
+			tr.record(func() { tr.connectStart = time.Now() })
+		},
+		ConnectDone: func(network, addr string, err error) {
+			tr.record(func() { tr.timing.Connect = time.Since(tr.connectStart) })
+		},
+		TLSHandshakeStart: func() {
+			tr.record(func() { tr.tlsStart = time.Now() })
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			tr.record(func() { tr.timing.TLSHandshake = time.Since(tr.tlsStart) })
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			tr.record(func() { tr.wrote = time.Now() })
+		},
+		GotFirstResponseByte: func() {
+			tr.record(func() {
+				if tr.wrote.IsZero() {
+					tr.wrote = tr.start
+				}
+				tr.timing.TimeToFirstByte = time.Since(tr.wrote)
+			})
+		},
+	}
+}
+
+// send executes the request while tracing it. The response body is read in
+// full so its download time is measured, then replaced with an in-memory copy
+// so it can still be parsed later. The body is also returned for reports.
+func send(client *http.Client, req *http.Request) (*http.Response, []byte, Timing, error) {
+	if req.URL.Scheme == "unix" {
+		var err error
+		if client, req, err = unixRequest(client, req); err != nil {
+			return nil, nil, Timing{}, err
+		}
+	}
+
+	tr := &tracer{}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), tr.clientTrace()))
+
+	tr.record(func() {
+		tr.start = time.Now()
+		tr.timing.Start = tr.start
+	})
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, nil, tr.snapshot(), err
+	}
+
+	downloadStart := time.Now()
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, nil, tr.snapshot(), err
+	}
+
+	timing := tr.snapshot()
+	timing.Download = time.Since(downloadStart)
+	timing.Total = time.Since(timing.Start)
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	return res, body, timing, nil
+}
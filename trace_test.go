@@ -0,0 +1,93 @@
+package irest
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestTiming(t *testing.T) {
+	test := NewTest("unit-test")
+
+	sample := SampleObject{}
+	first := test.NewTest("first").Get(api.URL, "/tests").ParseResponseBody(&sample)
+	if first.Error != nil {
+		t.Fatal(first.Error)
+	}
+
+	if sample.Name != "unit-test" {
+		t.Errorf("expected body to be readable after timing, got name %s", sample.Name)
+	}
+
+	if first.Timing.Total <= 0 || first.Duration != first.Timing.Total {
+		t.Errorf("expected duration %s to equal total timing %s", first.Duration, first.Timing.Total)
+	}
+
+	if first.Timing.TimeToFirstByte <= 0 {
+		t.Error("expected time to first byte to be recorded")
+	}
+
+	second := test.NewTest("second").Get(api.URL, "/tests")
+	if !second.Timing.ConnReused {
+		t.Error("expected keep-alive connection to be reused")
+	}
+}
+
+func TestRequestTimingTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	test := NewTest("unit-test")
+	test.Client = server.Client()
+	test = test.Get(server.URL, "/")
+
+	if test.Error != nil {
+		t.Fatal(test.Error)
+	}
+
+	if test.Timing.TLSHandshake <= 0 || test.Timing.Connect <= 0 {
+		t.Errorf("expected connect and tls handshake timings, got %s", test.Timing)
+	}
+}
+
+func TestEndpointTiming(t *testing.T) {
+	e := &Endpoint{Path: "/tests", Method: http.MethodGet}
+
+	et := e.Use(api.URL, nil).Do()
+	if et.Error != nil {
+		t.Fatal(et.Error)
+	}
+
+	if et.Timing.Total <= 0 || et.Duration != et.Timing.Total {
+		t.Errorf("expected duration %s to equal total timing %s", et.Duration, et.Timing.Total)
+	}
+}
+
+func TestReportTimingBreakdown(t *testing.T) {
+	test := NewTest("unit-test")
+	test.NewTest("get").Get(api.URL, "/tests")
+
+	report := NewColoredCommandLineReport(test)
+
+	var b bytes.Buffer
+	report.WriteReport(&b)
+
+	output := b.String()
+	if !strings.Contains(output, "ttfb") {
+		t.Error("expected timing breakdown in report output:", output)
+	}
+
+	report.TimingBreakdown = false
+
+	b.Reset()
+	report.WriteReport(&b)
+
+	output = b.String()
+	if strings.Contains(output, "ttfb") {
+		t.Error("expected no timing breakdown in report output:", output)
+	}
+}
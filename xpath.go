@@ -0,0 +1,432 @@
+package irest
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// xmlNode is an element of a parsed XML document. Names are local names, so
+// expressions match elements and attributes whatever their namespace.
+type xmlNode struct {
+	name     string
+	attrs    map[string]string
+	text     string
+	children []*xmlNode
+}
+
+// parseXML parses a document into a root node holding the document element.
+func parseXML(body []byte) (*xmlNode, error) {
+	root := &xmlNode{}
+	stack := []*xmlNode{root}
+
+	d := xml.NewDecoder(bytes.NewReader(body))
+	d.Strict = false
+	for {
+		token, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid XML: %s", err)
+		}
+
+		parent := stack[len(stack)-1]
+		switch tok := token.(type) {
+		case xml.StartElement:
+			n := &xmlNode{name: tok.Name.Local, attrs: map[string]string{}}
+			for _, a := range tok.Attr {
+				n.attrs[a.Name.Local] = a.Value
+			}
+			parent.children = append(parent.children, n)
+			stack = append(stack, n)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			parent.text += string(tok)
+		}
+	}
+
+	if len(root.children) == 0 {
+		return nil, fmt.Errorf("invalid XML: no document element")
+	}
+	return root, nil
+}
+
+// value returns the text of the node and its descendants.
+func (n *xmlNode) value() string {
+	var b strings.Builder
+	var walk func(*xmlNode)
+	walk = func(n *xmlNode) {
+		b.WriteString(n.text)
+		for _, c := range n.children {
+			walk(c)
+		}
+	}
+	walk(n)
+	return strings.TrimSpace(b.String())
+}
+
+func (n *xmlNode) descendantsOrSelf(nodes []*xmlNode) []*xmlNode {
+	nodes = append(nodes, n)
+	for _, c := range n.children {
+		nodes = c.descendantsOrSelf(nodes)
+	}
+	return nodes
+}
+
+// xpathStep is one location step of an expression.
+type xpathStep struct {
+	descendant bool
+	test       string
+	predicates []string
+}
+
+// parseXPath splits an expression into steps, keeping slashes and brackets
+// inside predicates and quotes.
+func parseXPath(expr string) ([]xpathStep, error) {
+	if expr == "" {
+		return nil, fmt.Errorf("empty XPath")
+	}
+
+	steps := []xpathStep{}
+	for i := 0; i < len(expr); {
+		step := xpathStep{}
+		switch {
+		case strings.HasPrefix(expr[i:], "//"):
+			step.descendant = true
+			i += 2
+		case expr[i] == '/':
+			i++
+		case i > 0:
+			return nil, fmt.Errorf("invalid XPath %s", expr)
+		}
+
+		start, depth, quote := i, 0, byte(0)
+		testEnd, predicateStart := -1, 0
+		for ; i < len(expr); i++ {
+			c := expr[i]
+			if quote != 0 {
+				if c == quote {
+					quote = 0
+				}
+				continue
+			}
+			if testEnd >= 0 && depth == 0 && c != '[' && c != '/' {
+				return nil, fmt.Errorf("invalid XPath %s", expr)
+			}
+			if c == '\'' || c == '"' {
+				quote = c
+			} else if c == '[' {
+				if depth == 0 {
+					if testEnd < 0 {
+						testEnd = i
+					}
+					predicateStart = i + 1
+				}
+				depth++
+			} else if c == ']' {
+				depth--
+				if depth == 0 {
+					step.predicates = append(step.predicates, strings.TrimSpace(expr[predicateStart:i]))
+				}
+			} else if c == '/' && depth == 0 {
+				break
+			}
+		}
+		if quote != 0 || depth != 0 {
+			return nil, fmt.Errorf("invalid XPath %s", expr)
+		}
+
+		if testEnd < 0 {
+			testEnd = i
+		}
+		step.test = expr[start:testEnd]
+		if step.test == "" {
+			return nil, fmt.Errorf("invalid XPath %s", expr)
+		}
+		steps = append(steps, step)
+	}
+
+	return steps, nil
+}
+
+// xpath evaluates an expression from the root node and returns the string
+// values of what it selects, in document order. It supports a subset of
+// XPath: child and descendant steps, "*", "@attr" and "text()" as last step,
+// and predicates of a position, "last()", "@attr", "@attr='value'",
+// "child='value'" and "text()='value'".
+func (n *xmlNode) xpath(expr string) ([]string, error) {
+	steps, err := parseXPath(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	context := []*xmlNode{n}
+	for i, step := range steps {
+		last := i == len(steps)-1
+
+		if strings.HasPrefix(step.test, "@") || step.test == "text()" {
+			if !last {
+				return nil, fmt.Errorf("invalid XPath %s, %s must be the last step", expr, step.test)
+			}
+			values := []string{}
+			for _, c := range stepContext(context, step.descendant) {
+				if step.test == "text()" {
+					if text := strings.TrimSpace(c.text); text != "" {
+						values = append(values, text)
+					}
+				} else if v, ok := c.attrs[step.test[1:]]; ok {
+					values = append(values, v)
+				}
+			}
+			return values, nil
+		}
+
+		next := []*xmlNode{}
+		seen := map[*xmlNode]bool{}
+		for _, c := range stepContext(context, step.descendant) {
+			matched := []*xmlNode{}
+			for _, child := range c.children {
+				if step.test == "*" || step.test == child.name {
+					matched = append(matched, child)
+				}
+			}
+
+			for _, p := range step.predicates {
+				if matched, err = filterPredicate(matched, p); err != nil {
+					return nil, fmt.Errorf("invalid XPath %s: %s", expr, err)
+				}
+			}
+
+			for _, m := range matched {
+				if !seen[m] {
+					seen[m] = true
+					next = append(next, m)
+				}
+			}
+		}
+		context = next
+	}
+
+	values := make([]string, len(context))
+	for i, c := range context {
+		values[i] = c.value()
+	}
+	return values, nil
+}
+
+// stepContext returns the nodes whose children a step selects from.
+func stepContext(context []*xmlNode, descendant bool) []*xmlNode {
+	if !descendant {
+		return context
+	}
+	nodes := []*xmlNode{}
+	for _, c := range context {
+		nodes = c.descendantsOrSelf(nodes)
+	}
+	return nodes
+}
+
+// indexUnquoted returns the index of the first c outside of quoted literals,
+// or -1.
+func indexUnquoted(s string, c byte) int {
+	quote := byte(0)
+	for i := 0; i < len(s); i++ {
+		switch {
+		case quote != 0:
+			if s[i] == quote {
+				quote = 0
+			}
+		case s[i] == '\'' || s[i] == '"':
+			quote = s[i]
+		case s[i] == c:
+			return i
+		}
+	}
+	return -1
+}
+
+func filterPredicate(nodes []*xmlNode, predicate string) ([]*xmlNode, error) {
+	if predicate == "last()" {
+		if len(nodes) == 0 {
+			return nodes, nil
+		}
+		return nodes[len(nodes)-1:], nil
+	}
+
+	if position, err := strconv.Atoi(predicate); err == nil {
+		if position < 1 || position > len(nodes) {
+			return nil, nil
+		}
+		return nodes[position-1 : position], nil
+	}
+
+	name, value, hasValue := predicate, "", false
+	if i := indexUnquoted(predicate, '='); i >= 0 {
+		name, value, hasValue = strings.TrimSpace(predicate[:i]), strings.TrimSpace(predicate[i+1:]), true
+		if len(value) < 2 || (value[0] != '\'' && value[0] != '"') || value[len(value)-1] != value[0] {
+			return nil, fmt.Errorf("predicate value %s must be quoted", value)
+		}
+		value = value[1 : len(value)-1]
+	}
+
+	filtered := []*xmlNode{}
+	for _, n := range nodes {
+		switch {
+		case strings.HasPrefix(name, "@"):
+			v, ok := n.attrs[name[1:]]
+			if ok && (!hasValue || v == value) {
+				filtered = append(filtered, n)
+			}
+		case name == "text()":
+			if strings.TrimSpace(n.text) == value || !hasValue {
+				filtered = append(filtered, n)
+			}
+		default:
+			for _, c := range n.children {
+				if c.name == name && (!hasValue || c.value() == value) {
+					filtered = append(filtered, n)
+					break
+				}
+			}
+		}
+	}
+	return filtered, nil
+}
+
+// xpathValues evaluates an expression against an XML response body.
+func xpathValues(body []byte, expr string) ([]string, error) {
+	doc, err := parseXML(body)
+	if err != nil {
+		return nil, err
+	}
+	return doc.xpath(expr)
+}
+
+func checkXPath(body []byte, expr string, m Matcher) error {
+	values, err := xpathValues(body, expr)
+	if err != nil {
+		return err
+	}
+	if len(values) == 0 {
+		return fmt.Errorf("expected a match for XPath %s", expr)
+	}
+	if err := m(values[0]); err != nil {
+		return fmt.Errorf("XPath %s: %s", expr, err)
+	}
+	return nil
+}
+
+func checkXPathCount(body []byte, expr string, count int) error {
+	values, err := xpathValues(body, expr)
+	if err != nil {
+		return err
+	}
+	if len(values) != count {
+		return fmt.Errorf("expected %d matches for XPath %s, actual %d", count, expr, len(values))
+	}
+	return nil
+}
+
+// MustXPath sets the Test.Error if the XML response body has no match for
+// the XPath expression or the first match fails the matcher.
+func (t *Test) MustXPath(expr string, m Matcher) *Test {
+	if t.Error != nil {
+		return t
+	}
+
+	t.Error = checkXPath(t.responseBody, expr, m)
+
+	return t
+}
+
+// MustXPathCount sets the Test.Error if the XML response body does not have
+// the number of matches for the XPath expression.
+func (t *Test) MustXPathCount(expr string, count int) *Test {
+	if t.Error != nil {
+		return t
+	}
+
+	t.Error = checkXPathCount(t.responseBody, expr, count)
+
+	return t
+}
+
+// SaveXPath stores the first match for the XPath expression in the XML
+// response body in value.
+func (t *Test) SaveXPath(expr string, value *string) *Test {
+	if t.Error != nil {
+		return t
+	}
+
+	values, err := xpathValues(t.responseBody, expr)
+	if err != nil {
+		t.Error = err
+		return t
+	}
+
+	if len(values) == 0 {
+		t.Error = fmt.Errorf("no match for XPath %s to save", expr)
+		return t
+	}
+	*value = values[0]
+
+	return t
+}
+
+// MustXPath sets the EndpointTest.Error if the XML response body has no
+// match for the XPath expression or the first match fails the matcher.
+func (e *EndpointTest) MustXPath(expr string, m Matcher) *EndpointTest {
+	if e.Error != nil {
+		return e
+	}
+
+	e.Error = checkXPath(e.responseBody, expr, m)
+
+	return e
+}
+
+// MustXPathCount sets the EndpointTest.Error if the XML response body does
+// not have the number of matches for the XPath expression.
+func (e *EndpointTest) MustXPathCount(expr string, count int) *EndpointTest {
+	if e.Error != nil {
+		return e
+	}
+
+	e.Error = checkXPathCount(e.responseBody, expr, count)
+
+	return e
+}
+
+// SaveXPath will save the first match for the XPath expression in the XML
+// response body with the provided name as the savedName in the parent test.
+func (e *EndpointTest) SaveXPath(expr, savedName string) *EndpointTest {
+	if e.Error != nil {
+		return e
+	}
+
+	values, err := xpathValues(e.responseBody, expr)
+	if err != nil {
+		e.Error = err
+		return e
+	}
+
+	if len(values) == 0 {
+		e.Error = fmt.Errorf("no match for XPath %s to save", expr)
+		return e
+	}
+	if e.Parent == nil {
+		e.Error = fmt.Errorf("endpoint test has no parent test to save to")
+		return e
+	}
+	if e.Parent.savedValues == nil {
+		e.Parent.savedValues = map[string]string{}
+	}
+	e.Parent.savedValues[savedName] = values[0]
+
+	return e
+}
@@ -0,0 +1,126 @@
+package irest
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+const catalogXML = `<?xml version="1.0"?>
+<catalog xmlns="urn:books">
+  <book id="1" lang="en"><title>Go</title><price>30</price></book>
+  <book id="2" lang="fr"><title>Rust</title><price>35</price></book>
+  <shelf><book id="3"><title>Zig</title><tag name="a=b" note="x][y">x]y</tag></book></shelf>
+</catalog>`
+
+func TestXPath(t *testing.T) {
+	var xpathTests = []struct {
+		expr     string
+		expected []string
+	}{
+		{"/catalog/book/title", []string{"Go", "Rust"}},
+		{"//book/title", []string{"Go", "Rust", "Zig"}},
+		{"//book/@id", []string{"1", "2", "3"}},
+		{"/catalog/book[2]/title", []string{"Rust"}},
+		{"/catalog/book[last()]/@lang", []string{"fr"}},
+		{"//book[@lang='fr']/price", []string{"35"}},
+		{"//book[title='Go']/@id", []string{"1"}},
+		{"//book[@lang]/title/text()", []string{"Go", "Rust"}},
+		{"/catalog/*/book/title", []string{"Zig"}},
+		{"/catalog/book[3]", []string{}},
+		{"catalog/shelf", []string{"Zigx]y"}},
+		{"//tag[@name='a=b']/text()", []string{"x]y"}},
+		{"//tag[text()='x]y']/@name", []string{"a=b"}},
+		{"//tag[@note='x][y']/@name", []string{"a=b"}},
+		{`//book[@id="3"][tag='x]y']/title`, []string{"Zig"}},
+		{"//book[tag='a=b']/title", []string{}},
+	}
+
+	doc, err := parseXML([]byte(catalogXML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range xpathTests {
+		actual, err := doc.xpath(tt.expr)
+		if err != nil {
+			t.Errorf("%s: %s", tt.expr, err)
+			continue
+		}
+		if !reflect.DeepEqual(actual, tt.expected) {
+			t.Errorf("%s: expected %q, got %q", tt.expr, tt.expected, actual)
+		}
+	}
+}
+
+func TestXPathInvalid(t *testing.T) {
+	doc, err := parseXML([]byte(catalogXML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expr := range []string{"", "/catalog/book[1", "/catalog/@id/title", "//book[@lang=fr]", "/catalog//", "/catalog/book[1]x", "//tag[@name='a]"} {
+		if _, err := doc.xpath(expr); err == nil {
+			t.Errorf("%s: expected an error", expr)
+		}
+	}
+
+	if _, err := parseXML([]byte("not xml")); err == nil {
+		t.Error("expected an error parsing a document without an element")
+	}
+}
+
+func TestMustXPath(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		w.Write([]byte(catalogXML))
+	})
+
+	var title string
+	test := NewTest("xpath").Handler(handler).Get("", "/books").
+		MustXPath("//book[@id='2']/title", Equals("Rust")).
+		MustXPathCount("//book", 3).
+		SaveXPath("/catalog/book[1]/title", &title)
+	if test.Error != nil {
+		t.Fatal(test.Error)
+	}
+	if title != "Go" {
+		t.Errorf("expected saved title Go, got %s", title)
+	}
+
+	test = NewTest("xpath count").Handler(handler).Get("", "/books").MustXPathCount("//book", 2)
+	if test.Error == nil {
+		t.Error("expected an error for the wrong number of matches")
+	}
+
+	test = NewTest("xpath missing").Handler(handler).Get("", "/books").MustXPath("//author", Equals("x"))
+	if test.Error == nil {
+		t.Error("expected an error for no match")
+	}
+
+	test = NewTest("xpath value").Handler(handler).Get("", "/books").MustXPath("//book/title", Equals("Rust"))
+	if test.Error == nil {
+		t.Error("expected an error for a value not matching")
+	}
+}
+
+func TestEndpointSaveXPath(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		w.Write([]byte(catalogXML))
+	})
+
+	test := NewTest("endpoint xpath").Handler(handler)
+	endpoint := Endpoint{Path: "/books", Method: http.MethodGet}
+	e := endpoint.Use("", nil).In(test)
+	e.Do().MustXPathCount("//book", 3).SaveXPath("//shelf/book[last()]/title", "title")
+	if e.Error != nil {
+		t.Fatal(e.Error)
+	}
+	if title, ok := test.Saved("title"); title != "Zig" {
+		t.Errorf("expected saved title Zig, got %q, %t", title, ok)
+	}
+	if _, ok := test.Saved("missing"); ok {
+		t.Error("expected no value saved as missing")
+	}
+}